@@ -0,0 +1,177 @@
+"""
+Binance 插件共享的 HTTP 请求封装
+
+统一处理：
+  - 最优 IP 直连（见 _http.py）
+  - 按域名共享的令牌桶限流（见 _ratelimit.py）
+  - Binance 权重自适应限速：
+      * 每次响应读取 X-MBX-USED-WEIGHT-1M，记录当前分钟已用权重
+      * 已用权重超过高水位（默认限额的 80%，可通过环境变量 BINANCE_WEIGHT_HIGH_WATER 调整比例）时，
+        在下一次请求前按超出比例主动休眠，直到本分钟窗口结束
+      * 收到 429 / 418 时严格按 Retry-After 暂停该域名的所有请求
+"""
+
+import logging
+import os
+import threading
+import time
+from typing import Optional
+from urllib.error import HTTPError
+
+import _http
+from _ratelimit import RateLimitedError, get_limiter
+
+logger = logging.getLogger("data-collector-plugin")
+
+# ============================================================================
+# 配置
+# ============================================================================
+
+# 令牌桶：按域名共享（kline / symbol 插件共用）
+_RATE_LIMIT_RPM = 1200
+_RATE_LIMIT_BURST = 100
+
+# 各域名每分钟权重上限（现货 6000，U 本位合约 2400）
+_WEIGHT_LIMITS = {
+    "api.binance.com": 6000,
+    "fapi.binance.com": 2400,
+}
+_DEFAULT_WEIGHT_LIMIT = 1200
+
+_WEIGHT_HIGH_WATER_RATIO = float(os.environ.get("BINANCE_WEIGHT_HIGH_WATER", "0.8"))
+
+# 主动限速单次最长休眠，以及等待 Retry-After 的最长时间（超过则直接失败，避免阻塞整轮采集）
+_MAX_THROTTLE_SECONDS = 10.0
+_MAX_BAN_WAIT_SECONDS = 10.0
+
+# ============================================================================
+# 权重状态
+# ============================================================================
+
+
+class _WeightState:
+    """单个域名的权重使用情况。"""
+
+    def __init__(self, limit: int):
+        self.limit = limit
+        self.used_weight = 0
+        self.window_minute = 0
+        self.banned_until = 0.0
+        self.throttled_total = 0
+        self.banned_total = 0
+
+
+_weight_states: dict[str, _WeightState] = {}
+_weight_lock = threading.Lock()
+
+
+def _get_state(domain: str) -> _WeightState:
+    state = _weight_states.get(domain)
+    if state is None:
+        state = _WeightState(_WEIGHT_LIMITS.get(domain, _DEFAULT_WEIGHT_LIMIT))
+        _weight_states[domain] = state
+    return state
+
+
+def weight_stats() -> dict[str, dict]:
+    """各域名的权重指标快照，key 为域名。"""
+    now = time.time()
+    with _weight_lock:
+        return {
+            domain: {
+                "used_weight_1m": state.used_weight if state.window_minute == int(now // 60) else 0,
+                "weight_limit_1m": state.limit,
+                "high_water": int(state.limit * _WEIGHT_HIGH_WATER_RATIO),
+                "banned_for_seconds": round(max(0.0, state.banned_until - now), 2),
+                "throttled_total": state.throttled_total,
+                "banned_total": state.banned_total,
+            }
+            for domain, state in _weight_states.items()
+        }
+
+
+def _wait_before_request(domain: str):
+    """请求前检查封禁状态与权重高水位，必要时休眠或抛出 RateLimitedError。"""
+    now = time.time()
+    with _weight_lock:
+        state = _get_state(domain)
+        ban_wait = state.banned_until - now
+        throttle = 0.0
+        high_water = state.limit * _WEIGHT_HIGH_WATER_RATIO
+        if state.window_minute == int(now // 60) and state.used_weight >= high_water:
+            # 超出高水位越多，休眠越接近本分钟窗口的剩余时间
+            ratio = min(1.0, (state.used_weight - high_water) / max(1.0, state.limit - high_water))
+            window_left = 60 - now % 60
+            throttle = min(_MAX_THROTTLE_SECONDS, max(ratio, 0.1) * window_left)
+            state.throttled_total += 1
+
+    if ban_wait > 0:
+        if ban_wait > _MAX_BAN_WAIT_SECONDS:
+            raise RateLimitedError(domain, ban_wait)
+        logger.warning(f"[error.ratelimit] Binance 封禁冷却中，等待 Retry-After: domain={domain}, wait={ban_wait:.2f}s")
+        time.sleep(ban_wait)
+    elif throttle > 0:
+        logger.warning(f"Binance 权重超过高水位，主动限速: domain={domain}, sleep={throttle:.2f}s")
+        time.sleep(throttle)
+
+
+def _record_response_headers(domain: str, headers, status: int):
+    """记录响应中的权重与 Retry-After 信息。"""
+    if headers is None:
+        return
+    used = headers.get("X-MBX-USED-WEIGHT-1M")
+    retry_after = headers.get("Retry-After")
+    now = time.time()
+    with _weight_lock:
+        state = _get_state(domain)
+        if used is not None:
+            try:
+                state.used_weight = int(used)
+                state.window_minute = int(now // 60)
+            except ValueError:
+                pass
+        if status in (418, 429):
+            state.banned_total += 1
+            try:
+                wait = float(retry_after) if retry_after is not None else 60.0
+            except ValueError:
+                wait = 60.0
+            state.banned_until = max(state.banned_until, now + wait)
+    if status in (418, 429):
+        logger.error(f"[error.ratelimit] Binance 限流: domain={domain}, status={status}, "
+                     f"retry_after={retry_after}, used_weight_1m={used}")
+
+# ============================================================================
+# 请求入口
+# ============================================================================
+
+
+def get_json(
+    base_url: str,
+    path: str,
+    domain: str,
+    best_ip: Optional[str] = None,
+    timeout: float = 10,
+    weight: int = 1,
+):
+    """请求 Binance 接口并返回解析后的 JSON。
+
+    Args:
+        base_url: 如 https://api.binance.com
+        path: 含 query string 的请求路径
+        domain: API 域名，用于限流、权重统计和 Host header
+        best_ip: 最优 IP，None 时使用域名直连
+        timeout: 请求超时（秒）
+        weight: 该接口的请求权重，按权重消耗令牌
+    """
+    _wait_before_request(domain)
+    get_limiter(domain, _RATE_LIMIT_RPM, _RATE_LIMIT_BURST).acquire(weight)
+
+    try:
+        status, headers, data = _http.request_json(base_url, path, domain, best_ip=best_ip, timeout=timeout)
+    except HTTPError as e:
+        _record_response_headers(domain, e.headers, e.code)
+        raise
+
+    _record_response_headers(domain, headers, status)
+    return data
@@ -0,0 +1,28 @@
+"""
+K线 DataPoint 去重
+
+同一轮触发中，多个任务可能覆盖同一标的、同一周期的同一时间窗口
+（例如实时任务与补数任务重叠、重复配置的任务），直接写入会产生重复 K线。
+
+去重键为 (object_id, times)，调用方需保证传入的 data_points 属于同一个写入组
+（同一 dataset / freq）。同一根 K线出现多次时按传入顺序后写入者覆盖先写入者（last-write-wins），
+close / volume 等字段均取最后一条，交易所修正后的 K线（如补数拉到的成交量更小的版本）不会被丢弃。
+各 K线插件按 job 下发顺序汇总 data_points，结果与线程完成顺序无关。
+"""
+
+import logging
+
+logger = logging.getLogger("data-collector-plugin")
+
+
+def dedup_data_points(data_points: list[dict], label: str = "") -> list[dict]:
+    """按 (object_id, times) 去重（后写入者覆盖），返回按 object_id、times 排序后的 DataPoint 列表。"""
+    latest: dict[tuple, dict] = {}
+    for dp in data_points:
+        latest[(dp.get("object_id", ""), dp.get("times", ""))] = dp
+
+    dropped = len(data_points) - len(latest)
+    if dropped:
+        logger.info(f"K线去重: {label}, before={len(data_points)}, after={len(latest)}, dropped={dropped}")
+    return [latest[key] for key in sorted(latest)]
+
@@ -0,0 +1,63 @@
+"""
+插件共享的 HTTP 请求封装
+
+统一处理最优 IP 直连：有 best_ip 时将 URL 中的域名替换为 IP，
+设置 Host header 为原始域名，并关闭证书校验（证书绑定域名而非 IP）。
+配置 DC_RECORD_DIR / DC_REPLAY_DIR 时录制或回放响应（见 _replay.py）。
+"""
+
+import json
+import ssl
+from typing import Optional
+from urllib.request import Request, urlopen
+
+import _replay
+
+_USER_AGENT = "data-collector/1.0"
+
+
+def request_json(
+    base_url: str,
+    path: str,
+    domain: str,
+    best_ip: Optional[str] = None,
+    timeout: float = 10,
+):
+    """发起 GET 请求，返回 (status, headers, data)。
+
+    非 2xx 响应按 urllib 的行为抛出 HTTPError（可从 e.code / e.headers 读取状态与响应头）。
+    """
+    if _replay.enabled():
+        return _replay.load(domain, path)
+
+    url = f"{base_url}{path}"
+    req = Request(url)
+    req.add_header("User-Agent", _USER_AGENT)
+
+    if best_ip:
+        req = Request(url.replace(f"https://{domain}", f"https://{best_ip}"))
+        req.add_header("Host", domain)
+        req.add_header("User-Agent", _USER_AGENT)
+
+        ctx = ssl.create_default_context()
+        ctx.check_hostname = False
+        ctx.verify_mode = ssl.CERT_NONE
+        resp = urlopen(req, timeout=timeout, context=ctx)
+    else:
+        resp = urlopen(req, timeout=timeout)
+
+    data = json.loads(resp.read().decode())
+    _replay.save(domain, path, resp.status, resp.headers, data)
+    return resp.status, resp.headers, data
+
+
+def get_json(
+    base_url: str,
+    path: str,
+    domain: str,
+    best_ip: Optional[str] = None,
+    timeout: float = 10,
+):
+    """发起 GET 请求并返回解析后的 JSON。"""
+    _, _, data = request_json(base_url, path, domain, best_ip=best_ip, timeout=timeout)
+    return data
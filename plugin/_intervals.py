@@ -0,0 +1,117 @@
+"""
+K线周期的校验与标准化
+
+任务下发的周期字符串大小写、写法因交易所而异（1h / 1H / ONE_HOUR / 1Dutc / Kraken 的分钟数 60），
+各插件在 parse_job 中统一调用 parse_interval() 转为内部标准周期，
+无法识别的周期直接报错跳过，而不是带着非法参数请求交易所。
+
+内部标准周期：分钟 m、小时 h、天 d、周 w 均为小写，月为大写 M（与分钟 m 区分）。
+"""
+
+import re
+from typing import Optional
+
+CANONICAL_INTERVALS = (
+    "1m", "3m", "5m", "15m", "30m",
+    "1h", "2h", "4h", "6h", "8h", "12h",
+    "1d", "3d", "1w", "1M",
+)
+
+# 固定时长周期的毫秒数（1M 按自然月处理，不在此表中）
+INTERVAL_MS = {
+    "1m": 60_000,
+    "3m": 3 * 60_000,
+    "5m": 5 * 60_000,
+    "15m": 15 * 60_000,
+    "30m": 30 * 60_000,
+    "1h": 3_600_000,
+    "2h": 2 * 3_600_000,
+    "4h": 4 * 3_600_000,
+    "6h": 6 * 3_600_000,
+    "8h": 8 * 3_600_000,
+    "12h": 12 * 3_600_000,
+    "1d": 86_400_000,
+    "3d": 3 * 86_400_000,
+    "1w": 7 * 86_400_000,
+}
+
+# 各交易所的原生写法 → 内部标准周期
+_EXCHANGE_ALIASES = {
+    "okx": {
+        "6Hutc": "6h",
+        "12Hutc": "12h",
+        "1Dutc": "1d",
+        "3Dutc": "3d",
+        "1Wutc": "1w",
+        "1Mutc": "1M",
+    },
+    "coinbase": {
+        "ONE_MINUTE": "1m",
+        "FIVE_MINUTE": "5m",
+        "FIFTEEN_MINUTE": "15m",
+        "THIRTY_MINUTE": "30m",
+        "ONE_HOUR": "1h",
+        "TWO_HOUR": "2h",
+        "FOUR_HOUR": "4h",
+        "SIX_HOUR": "6h",
+        "ONE_DAY": "1d",
+    },
+    "kraken": {
+        "1": "1m",
+        "5": "5m",
+        "15": "15m",
+        "30": "30m",
+        "60": "1h",
+        "240": "4h",
+        "1440": "1d",
+        "10080": "1w",
+    },
+}
+
+# 小时/天/周的大写写法（1H / 1D / 1W），xData freq 与 OKX 均使用这种形式
+_UPPER_UNIT_RE = re.compile(r"^(\d+)([HDW])$")
+
+
+def parse_interval(raw: str, exchange: str = "", supported=None) -> str:
+    """将周期字符串标准化为内部周期。
+
+    Args:
+        raw: 任务下发的周期字符串
+        exchange: 交易所标识（如 "okx"），用于识别该交易所的原生写法
+        supported: 该插件支持的内部周期集合，None 表示全部标准周期
+
+    Raises:
+        ValueError: 周期无法识别，或不在 supported 范围内
+    """
+    interval = _normalize(raw, exchange)
+    allowed = CANONICAL_INTERVALS if supported is None else supported
+    if interval is None or interval not in allowed:
+        raise ValueError(f"不支持的周期: {raw!r}, 可选: {list(allowed)}")
+    return interval
+
+
+def to_freq(interval: str) -> str:
+    """内部周期 → xData freq（1h → 1H，1d → 1D，其余原样）。"""
+    if interval.endswith("h"):
+        return interval[:-1] + "H"
+    if interval.endswith("d"):
+        return interval[:-1] + "D"
+    return interval
+
+
+def _normalize(raw: str, exchange: str) -> Optional[str]:
+    if not isinstance(raw, str):
+        return None
+    raw = raw.strip()
+    if raw in CANONICAL_INTERVALS:
+        return raw
+
+    alias = _EXCHANGE_ALIASES.get(exchange, {}).get(raw)
+    if alias is not None:
+        return alias
+
+    m = _UPPER_UNIT_RE.match(raw)
+    if m:
+        interval = m.group(1) + m.group(2).lower()
+        return interval if interval in CANONICAL_INTERVALS else None
+    return None
@@ -0,0 +1,254 @@
+"""
+K线插件共享的采集流程与合法性校验
+
+collect_kline_jobs() 为各 K线插件共用的 collect 流程：并发执行 job、汇总 task_results、
+按 (inst_type, interval) 分组去重生成 write_groups，插件只需提供单个 job 的拉取与 DataPoint 格式化。
+
+collect_range() 为支持翻页的插件共用的区间任务（task_params.start_time）执行逻辑：
+插件只需提供从游标向后拉取一页的函数，单次触发的时间预算、页数上限与跨触发续传在这里统一处理。
+
+格式化 DataPoint 之前调用 validate_klines()，丢弃交易所异常响应中的非法 K线，
+校验规则（可通过 task_params.validate_kline=false 关闭）：
+  - 价格非负，high ≥ max(open, close)，low ≤ min(open, close)
+  - 成交量（volume / quote_volume，存在时）非负
+  - open_time 位于周期左边界（见 _timeutil.is_aligned）且严格递增
+
+各插件解析出的 K线 dict 需包含 open_ms、open_time、close_time、open、high、low、close、volume 字段。
+"""
+
+import logging
+import threading
+import time
+from concurrent.futures import ThreadPoolExecutor
+from typing import Optional
+
+from _dedup import dedup_data_points
+from _intervals import to_freq
+from _metrics import record_kline_validation_failed
+from _timeutil import format_ms, is_aligned
+
+logger = logging.getLogger("data-collector-plugin")
+
+STATUS_SUCCESS = 2
+STATUS_FAILED = 4
+
+# 单次触发内区间翻页的时间预算（秒），需小于引擎对 /on-trigger 的 30 秒超时；
+# 超出时写入已获取部分并上报 FAILED，下次触发从断点继续
+RANGE_TIME_BUDGET_SECONDS = 20
+
+# 本次触发的区间翻页截止时间（time.monotonic()），由 main.py 在每次触发开始时通过 begin_trigger() 设置，
+# 同一次触发中的所有 K线插件共用，多个 data_type 依次采集时总耗时也不超过预算
+_trigger_deadline: Optional[float] = None
+
+# 区间任务进度：(交易所, inst_type, symbol, interval, start_ms, end_ms) → 下次翻页的起点，仅在当前实例内存中保存
+_range_cursors: dict[tuple, int] = {}
+_range_cursors_lock = threading.Lock()
+
+
+def begin_trigger():
+    """开始一次触发：重置区间翻页的截止时间。"""
+    global _trigger_deadline
+    _trigger_deadline = time.monotonic() + RANGE_TIME_BUDGET_SECONDS
+
+
+def collect_kline_jobs(
+    jobs: list[dict],
+    get_best_ip,
+    data_type: str,
+    exchange: str,
+    fetch,
+    format_data_points,
+    dataset_ids: dict,
+) -> dict:
+    """执行 K线采集（并发），返回 {"task_results": [...], "write_groups": [...]}。
+
+    Args:
+        jobs: parse_job 返回的 dict 列表，需包含 task_id、inst_type、symbol、interval、domain、validate
+        get_best_ip: callable(domain) -> Optional[str]，获取最优 IP
+        data_type: 插件的 data_type，校验丢弃的 K线数按它记入指标（见 _metrics.py）
+        exchange: 交易所名称，用于日志
+        fetch: callable(job, best_ip, deadline) -> (klines, err_msg)，拉取单个 job 的 K线；
+            deadline 为本次触发区间翻页的截止时间（time.monotonic()），err_msg 非 None 时已获取的 K线照常写入，task 上报 FAILED
+        format_data_points: callable(symbol, klines) -> list[dict]，K线转为 DataPoint
+        dataset_ids: inst_type → dataset_id
+
+    fetch 抛出异常只使该 job 所属的 task 失败；同一 task 的任一 job 失败即整体失败。
+    """
+    if not jobs:
+        return {"task_results": [], "write_groups": []}
+
+    logger.info(f"本轮 {exchange} K线采集: {len(jobs)} 个任务, 标的: [{', '.join(_describe(j) for j in jobs)}]")
+    deadline = _trigger_deadline if _trigger_deadline is not None else time.monotonic() + RANGE_TIME_BUDGET_SECONDS
+
+    def _do_collect(job):
+        try:
+            klines, err_msg = fetch(job, get_best_ip(job["domain"]), deadline)
+            if job["validate"]:
+                fetched = len(klines)
+                klines = validate_klines(job["symbol"], job["interval"], klines)
+                if len(klines) < fetched:
+                    record_kline_validation_failed(data_type, fetched - len(klines))
+            if err_msg is None:
+                logger.info(f"{exchange} 采集成功: {_describe(job)}, count={len(klines)}")
+            return err_msg, format_data_points(job["symbol"], klines)
+        except Exception as e:
+            logger.error(f"{exchange} 采集失败: {_describe(job)}, error={e}")
+            return str(e), []
+
+    task_errors: dict[str, list[str]] = {}
+    task_ids: dict[str, None] = {}
+    grouped_points: dict[tuple, list[dict]] = {}
+    succeeded = []
+    with ThreadPoolExecutor(max_workers=min(len(jobs), 10)) as executor:
+        futures = [executor.submit(_do_collect, job) for job in jobs]
+        # 按 job 下发顺序（而非完成顺序）汇总，去重的 last-write-wins 才有确定的先后（见 _dedup.py）
+        for job, future in zip(jobs, futures):
+            err_msg, data_points = future.result()
+            task_ids[job["task_id"]] = None
+            if data_points:
+                grouped_points.setdefault((job["inst_type"], job["interval"]), []).extend(data_points)
+            if err_msg is None:
+                succeeded.append(_describe(job))
+            else:
+                task_errors.setdefault(job["task_id"], []).append(f"{_describe(job)}: {err_msg}")
+
+    failed = [msg for errors in task_errors.values() for msg in errors]
+    logger.info(f"本轮 {exchange} K线采集完成: 成功={len(succeeded)}, 失败={len(failed)}"
+                f", 成功标的=[{', '.join(succeeded)}]"
+                + (f", 失败标的=[{'; '.join(failed)}]" if failed else ""))
+
+    task_results = []
+    for task_id in task_ids:
+        if task_id in task_errors:
+            task_results.append({"task_id": task_id, "status": STATUS_FAILED, "result": "; ".join(task_errors[task_id])})
+        else:
+            task_results.append({"task_id": task_id, "status": STATUS_SUCCESS, "result": ""})
+
+    # 按 (inst_type, interval) 分组写入，避免不同周期混用同一个 freq；
+    # 组内按 (symbol, open_time) 去重，避免多个任务覆盖同一时间窗口时重复写入
+    write_groups = []
+    for (inst_type, interval), data_points in grouped_points.items():
+        data_points = dedup_data_points(data_points, label=f"{exchange} {inst_type}/{interval}")
+        if not data_points:
+            continue
+        write_groups.append({
+            "write_mode": "set_data",
+            "dataset_id": dataset_ids[inst_type],
+            "freq": to_freq(interval),
+            "data_points": data_points,
+        })
+
+    return {"task_results": task_results, "write_groups": write_groups}
+
+
+def collect_range(
+    key: tuple,
+    start_ms: int,
+    end_ms: Optional[int],
+    fetch_page,
+    deadline: float,
+    max_pages: int,
+) -> tuple[list[dict], Optional[str]]:
+    """执行 [start_ms, end_ms) 区间任务，返回 (本次获取的 K线, 未完成时的说明)。
+
+    fetch_page(cursor_ms, end_ms) -> (klines, next_cursor_ms)：从 cursor 开始向后拉取一页（K线按 open_time 升序），
+    next_cursor ≥ end_ms 表示区间已拉取完。
+
+    区间按 _range_cursors 中的进度续传：每页请求前检查 deadline（time.monotonic()）与 max_pages，到达任一即停止
+    （截止时间之后才开始的 job 不发起请求），已获取的部分照常返回，未完成的部分留给下次触发。
+    指定 end_ms 的区间完成后，后续触发不再重复拉取；
+    未指定 end_ms 时以当前时间为终点，完成后从最后一根（可能未收盘）开始增量拉取。
+    """
+    open_ended = end_ms is None
+    if open_ended:
+        end_ms = int(time.time() * 1000)
+    if start_ms >= end_ms:
+        raise ValueError(f"时间范围无效: start={format_ms(start_ms)}, end={format_ms(end_ms)}")
+
+    with _range_cursors_lock:
+        cursor = _range_cursors.get(key, start_ms)
+    if cursor >= end_ms:
+        logger.info(f"区间已采集完成，跳过: key={key}")
+        return [], None
+
+    klines = []
+    pages = 0
+    reason = None
+    resumed_from = cursor
+    while cursor < end_ms:
+        if pages >= max_pages:
+            reason = f"单次触发最多 {max_pages} 页"
+            break
+        if time.monotonic() >= deadline:
+            reason = f"单次触发限时 {RANGE_TIME_BUDGET_SECONDS}s"
+            break
+        try:
+            page, next_cursor = fetch_page(cursor, end_ms)
+        except Exception as e:
+            reason = f"请求失败: {e}"
+            break
+        pages += 1
+        klines.extend(page)
+        if next_cursor <= cursor:
+            reason = f"分页游标未推进: cursor={format_ms(cursor)}"
+            break
+        cursor = next_cursor
+
+    if reason is None and open_ended:
+        cursor = klines[-1]["open_ms"] if klines else resumed_from
+    with _range_cursors_lock:
+        _range_cursors[key] = cursor
+    logger.info(f"区间采集: key={key}, pages={pages}, count={len(klines)}, done={reason is None}")
+    if reason is None:
+        return klines, None
+    err_msg = f"区间采集未完成（{reason}），已采集至 {format_ms(cursor)}，下次触发继续"
+    logger.warning(f"{err_msg}: key={key}, count={len(klines)}")
+    return klines, err_msg
+
+
+def validate_kline(kline: dict) -> Optional[str]:
+    """校验单根 K线的 OHLCV 合法性，合法返回 None，否则返回错误描述。"""
+    open_, high, low, close = kline["open"], kline["high"], kline["low"], kline["close"]
+    if min(open_, high, low, close) < 0:
+        return "价格为负"
+    if high < max(open_, close):
+        return f"high({high}) < max(open, close)({max(open_, close)})"
+    if low > min(open_, close):
+        return f"low({low}) > min(open, close)({min(open_, close)})"
+    if kline["volume"] < 0 or kline.get("quote_volume", 0) < 0:
+        return f"成交量为负: volume={kline['volume']}, quote_volume={kline.get('quote_volume')}"
+    if kline["close_time"] < kline["open_time"]:
+        return f"close_time({kline['close_time']}) < open_time({kline['open_time']})"
+    return None
+
+
+def validate_klines(symbol: str, interval: str, klines: list[dict]) -> list[dict]:
+    """过滤不合法的 K线，并校验 open_time 位于周期左边界且严格递增（时间约定见 _timeutil.py）。
+
+    不合法的 K线直接丢弃（不写入存储），逐根以 error 级别记录具体字段，便于排查交易所异常响应；
+    有丢弃时再按 symbol / interval 汇总记录丢弃数量。
+    """
+    valid = []
+    last_open_time = ""
+    for kline in klines:
+        reason = validate_kline(kline)
+        if reason is None and not is_aligned(kline["open_ms"], interval):
+            reason = f"open_time 未对齐周期边界: interval={interval}, open_time={kline['open_time']}"
+        if reason is None and last_open_time and kline["open_time"] <= last_open_time:
+            reason = f"open_time 非递增: prev={last_open_time}, current={kline['open_time']}"
+        if reason is not None:
+            logger.error(f"K线校验失败, 已丢弃: symbol={symbol}, interval={interval}, "
+                         f"reason={reason}, kline={kline}")
+            continue
+        last_open_time = kline["open_time"]
+        valid.append(kline)
+
+    dropped = len(klines) - len(valid)
+    if dropped:
+        logger.warning(f"K线校验完成: symbol={symbol}, interval={interval}, "
+                       f"total={len(klines)}, dropped={dropped}")
+    return valid
+
+
+def _describe(job: dict) -> str:
+    return f"{job['symbol']}/{job['inst_type']}/{job['interval']}"
@@ -0,0 +1,110 @@
+"""
+Kraken 插件共享的请求封装与代码映射
+
+统一处理：
+  - 最优 IP 直连（见 _http.py）与按域名共享的令牌桶限流（见 _ratelimit.py）
+  - Kraken 响应体中的 error 数组（HTTP 200 也可能是业务错误）
+  - Kraken 资产代码与内部写法的互转：
+      * 历史资产带 X / Z 前缀（XXBT、XETH、ZUSD），且 BTC 记为 XBT、DOGE 记为 XDG
+      * 交易对代码为 base + quote 拼接（XXBTZUSD），altname 为 XBTUSD
+      * 内部 symbol 统一为 BTC-USD 形式
+"""
+
+import logging
+from typing import Optional
+from urllib.error import URLError, HTTPError
+
+import _http
+import _symbols
+from _ratelimit import get_limiter
+
+logger = logging.getLogger("data-collector-plugin")
+
+# ============================================================================
+# 配置
+# ============================================================================
+
+KRAKEN_BASE = "https://api.kraken.com"
+KRAKEN_DOMAIN = "api.kraken.com"
+
+# 公共接口按 IP 计数，约 1 次/秒（kline / symbol 插件共用同一个令牌桶）
+_RATE_LIMIT_RPM = 60
+_RATE_LIMIT_BURST = 5
+
+# Kraken 资产代码 → 内部资产代码（未列出的原样使用）
+_ASSET_ALIASES = {
+    "XBT": "BTC",
+    "XXBT": "BTC",
+    "XDG": "DOGE",
+    "XXDG": "DOGE",
+    "XETH": "ETH",
+    "XETC": "ETC",
+    "XLTC": "LTC",
+    "XXRP": "XRP",
+    "XXLM": "XLM",
+    "XXMR": "XMR",
+    "XZEC": "ZEC",
+    "XMLN": "MLN",
+    "XREP": "REP",
+    "ZUSD": "USD",
+    "ZEUR": "EUR",
+    "ZGBP": "GBP",
+    "ZJPY": "JPY",
+    "ZCAD": "CAD",
+    "ZAUD": "AUD",
+}
+
+# 内部资产代码 → Kraken altname 中使用的代码
+_ALTNAME_ASSETS = {"BTC": "XBT", "DOGE": "XDG"}
+
+# 无分隔符 symbol（XBTUSD）拆分时识别的计价币种。
+# 不含 TUSD 等以 USD 结尾的稳定币，否则 XBTUSD 会同时匹配 XB-TUSD 与 XBT-USD
+_KNOWN_QUOTES = ("USDT", "USDC", "USD", "EUR", "GBP", "BTC", "ETH")
+
+# ============================================================================
+# 代码映射
+# ============================================================================
+
+
+def normalize_asset(asset: str) -> str:
+    """Kraken 资产代码 → 内部资产代码（XXBT / XBT → BTC，ZUSD → USD）。"""
+    asset = asset.upper()
+    return _ASSET_ALIASES.get(asset, asset)
+
+
+def to_pair(symbol: str) -> str:
+    """内部 symbol（BTC-USD / BTCUSD）→ Kraken 请求用的 altname（XBTUSD）。"""
+    base, quote = split_symbol(symbol)
+    return f"{_ALTNAME_ASSETS.get(base, base)}{_ALTNAME_ASSETS.get(quote, quote)}"
+
+
+def split_symbol(symbol: str) -> tuple[str, str]:
+    """拆分内部 symbol 为 (base, quote)，资产代码统一为内部写法（XBT-USD / XETH/ZUSD → ETH, USD）。
+
+    也接受 Kraken 交易对完整代码：两个带 X / Z 前缀的四位资产代码直接拼接（XXBTZUSD、XETHZUSD）。
+    """
+    compact = symbol.strip().upper()
+    if len(compact) == 8 and compact[:4] in _ASSET_ALIASES and compact[4:] in _ASSET_ALIASES:
+        return normalize_asset(compact[:4]), normalize_asset(compact[4:])
+    base, quote = _symbols.split_symbol(symbol, _KNOWN_QUOTES)
+    return normalize_asset(base), normalize_asset(quote)
+
+# ============================================================================
+# 请求入口
+# ============================================================================
+
+
+def get_result(path: str, best_ip: Optional[str] = None, timeout: float = 10) -> dict:
+    """请求 Kraken 公共接口，返回响应中的 result 字段；error 非空时抛出 RuntimeError。"""
+    get_limiter(KRAKEN_DOMAIN, _RATE_LIMIT_RPM, _RATE_LIMIT_BURST).acquire()
+
+    try:
+        raw = _http.get_json(KRAKEN_BASE, path, KRAKEN_DOMAIN, best_ip=best_ip, timeout=timeout)
+    except (URLError, HTTPError) as e:
+        logger.error(f"Kraken API 请求失败: path={path}, error={e}")
+        raise
+
+    errors = raw.get("error") or []
+    if errors:
+        raise RuntimeError(f"Kraken API 返回错误: path={path}, error={errors}")
+    return raw.get("result") or {}
@@ -0,0 +1,99 @@
+"""
+插件运行指标
+
+按 data_type 累计每个采集插件的执行情况（执行次数、任务成功/失败数、产出数据点数、耗时），
+由 main.py 在每次调用 collect 后记录；K线插件另外记录校验丢弃的 K线数（由 _klines.py 记录）。
+通过 GET /collectors（按插件）与 GET /health（汇总）导出。
+指标仅保存在当前进程内存中，实例重启后清零。
+"""
+
+import threading
+import time
+from typing import Optional
+
+STATUS_SUCCESS = 2
+
+_started_at = time.time()
+_metrics: dict[str, dict] = {}
+_metrics_lock = threading.Lock()
+
+
+def record_collect(data_type: str, result: dict, duration: float, error: Optional[str] = None):
+    """记录一次 collect 调用的结果。collect 抛出异常时 result 传空 dict，并通过 error 传入异常信息。"""
+    task_results = result.get("task_results") or []
+    succeeded = sum(1 for r in task_results if r.get("status") == STATUS_SUCCESS)
+    data_points = sum(len(g.get("data_points") or []) for g in result.get("write_groups") or [])
+    now = time.time()
+
+    with _metrics_lock:
+        m = _entry(data_type)
+        m["runs_total"] += 1
+        m["tasks_succeeded_total"] += succeeded
+        m["tasks_failed_total"] += len(task_results) - succeeded
+        m["data_points_total"] += data_points
+        m["duration_seconds_total"] += duration
+        m["last_run_at"] = round(now, 3)
+        m["last_duration_seconds"] = round(duration, 3)
+        if data_points:
+            m["last_data_at"] = round(now, 3)
+        if error is not None:
+            m["run_errors_total"] += 1
+            m["last_error"] = error
+
+
+def record_kline_validation_failed(data_type: str, dropped: int):
+    """记录校验不通过而被丢弃的 K线数。"""
+    with _metrics_lock:
+        _entry(data_type)["kline_validation_failed_total"] += dropped
+
+
+def collector_metrics(data_type: str) -> Optional[dict]:
+    """单个插件的指标快照，尚未执行过时返回 None。"""
+    with _metrics_lock:
+        m = _metrics.get(data_type)
+        return _snapshot(m) if m is not None else None
+
+
+def metrics_summary() -> dict:
+    """所有插件的汇总指标。"""
+    uptime = time.time() - _started_at
+    with _metrics_lock:
+        metrics = list(_metrics.values())
+    data_points = sum(m["data_points_total"] for m in metrics)
+    tasks_failed = sum(m["tasks_failed_total"] for m in metrics)
+    tasks_total = tasks_failed + sum(m["tasks_succeeded_total"] for m in metrics)
+    return {
+        "uptime_seconds": round(uptime, 1),
+        "runs_total": sum(m["runs_total"] for m in metrics),
+        "tasks_total": tasks_total,
+        "tasks_failed_total": tasks_failed,
+        "task_error_rate": round(tasks_failed / tasks_total, 4) if tasks_total else 0.0,
+        "data_points_total": data_points,
+        "kline_validation_failed_total": sum(m["kline_validation_failed_total"] for m in metrics),
+        "data_points_per_minute": round(data_points / uptime * 60, 2) if uptime > 0 else 0.0,
+    }
+
+
+def _entry(data_type: str) -> dict:
+    """取出（不存在则创建）data_type 的指标，调用方需持有 _metrics_lock。"""
+    return _metrics.setdefault(data_type, {
+        "runs_total": 0,
+        "run_errors_total": 0,
+        "tasks_succeeded_total": 0,
+        "tasks_failed_total": 0,
+        "data_points_total": 0,
+        "kline_validation_failed_total": 0,
+        "duration_seconds_total": 0.0,
+        "last_run_at": None,
+        "last_duration_seconds": None,
+        "last_data_at": None,
+        "last_error": None,
+    })
+
+
+def _snapshot(m: dict) -> dict:
+    snapshot = dict(m)
+    snapshot["duration_seconds_total"] = round(m["duration_seconds_total"], 3)
+    tasks_total = m["tasks_succeeded_total"] + m["tasks_failed_total"]
+    snapshot["task_error_rate"] = round(m["tasks_failed_total"] / tasks_total, 4) if tasks_total else 0.0
+    return snapshot
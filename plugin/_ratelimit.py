@@ -0,0 +1,112 @@
+"""
+插件共享的令牌桶限流器
+
+同一进程内的所有采集插件按 key（通常为 API 域名）共享同一个令牌桶，
+避免多个插件/并发线程各自请求导致整体超出交易所的请求配额（如 Binance 的权重限制被封 IP）。
+"""
+
+import threading
+import time
+from typing import Optional
+
+
+class RateLimitedError(Exception):
+    """在等待上限内无法获取到令牌时抛出。"""
+
+    def __init__(self, name: str, wait_seconds: float):
+        super().__init__(f"触发限流: limiter={name}, 需等待 {wait_seconds:.2f}s")
+        self.name = name
+        self.wait_seconds = wait_seconds
+
+
+class TokenBucket:
+    """令牌桶：按 requests_per_minute 匀速补充令牌，桶容量为 burst。线程安全。"""
+
+    def __init__(self, name: str, requests_per_minute: float, burst: int):
+        if requests_per_minute <= 0 or burst <= 0:
+            raise ValueError(f"限流参数无效: requests_per_minute={requests_per_minute}, burst={burst}")
+        self.name = name
+        self.requests_per_minute = requests_per_minute
+        self.burst = burst
+        self._rate = requests_per_minute / 60.0
+        self._tokens = float(burst)
+        self._updated_at = time.monotonic()
+        self._lock = threading.Lock()
+        self._acquired_total = 0
+        self._rejected_total = 0
+        self._last_acquired_at = 0.0
+
+    def acquire(self, tokens: float = 1, timeout: Optional[float] = 10.0):
+        """获取令牌，不足时阻塞等待；预计等待时间超过 timeout 时抛出 RateLimitedError。
+
+        timeout 为 None 表示一直等待，为 0 表示不等待。
+        """
+        if tokens > self.burst:
+            raise ValueError(f"单次请求令牌数超过桶容量: tokens={tokens}, burst={self.burst}")
+        deadline = None if timeout is None else time.monotonic() + timeout
+        while True:
+            with self._lock:
+                self._refill()
+                if self._tokens >= tokens:
+                    self._tokens -= tokens
+                    self._acquired_total += 1
+                    self._last_acquired_at = time.time()
+                    return
+                wait = (tokens - self._tokens) / self._rate
+                if deadline is not None and time.monotonic() + wait > deadline:
+                    self._rejected_total += 1
+                    raise RateLimitedError(self.name, wait)
+            time.sleep(wait)
+
+    def try_acquire(self, tokens: float = 1) -> bool:
+        """非阻塞获取令牌，成功返回 True。"""
+        try:
+            self.acquire(tokens, timeout=0)
+            return True
+        except RateLimitedError:
+            return False
+
+    def remaining(self) -> float:
+        """当前剩余令牌数。"""
+        with self._lock:
+            self._refill()
+            return self._tokens
+
+    def stats(self) -> dict:
+        """限流器指标快照。"""
+        with self._lock:
+            self._refill()
+            return {
+                "requests_per_minute": self.requests_per_minute,
+                "burst": self.burst,
+                "remaining": round(self._tokens, 2),
+                "acquired_total": self._acquired_total,
+                "rejected_total": self._rejected_total,
+                "last_acquired_at": round(self._last_acquired_at, 3) if self._last_acquired_at else None,
+            }
+
+    def _refill(self):
+        now = time.monotonic()
+        self._tokens = min(self.burst, self._tokens + (now - self._updated_at) * self._rate)
+        self._updated_at = now
+
+
+_limiters: dict[str, TokenBucket] = {}
+_limiters_lock = threading.Lock()
+
+
+def get_limiter(name: str, requests_per_minute: float, burst: int) -> TokenBucket:
+    """按 name 获取（首次调用时创建）共享限流器，后续调用的参数以首次创建时为准。"""
+    with _limiters_lock:
+        limiter = _limiters.get(name)
+        if limiter is None:
+            limiter = TokenBucket(name, requests_per_minute, burst)
+            _limiters[name] = limiter
+        return limiter
+
+
+def limiter_stats() -> dict[str, dict]:
+    """所有已创建限流器的指标快照，key 为限流器名称。"""
+    with _limiters_lock:
+        limiters = list(_limiters.values())
+    return {limiter.name: limiter.stats() for limiter in limiters}
@@ -0,0 +1,94 @@
+"""
+交易所响应的录制与回放
+
+用于在不访问交易所的情况下调试采集插件（解析、校验、去重、分组写入），由环境变量控制：
+  - DC_RECORD_DIR: 录制模式，每次请求的响应（状态码、响应头、JSON）写入该目录
+  - DC_REPLAY_DIR: 回放模式，只从该目录读取录制的响应，不发起网络请求；找不到对应录制时按请求失败处理
+
+录制文件按 {domain}/{key}.json 存放，key 由请求路径（含 query string）生成。
+增量采集的请求参数中带有当前时间（endTime / since / start / end），回放时先按完整路径匹配，
+未命中再按去掉这些参数后的路径匹配该接口最近一次录制的响应，保证同一组录制可以重复回放、结果一致。
+分页游标（Binance 的 startTime、OKX 的 after / before）始终参与匹配：每一页回放各自录制的响应，
+不会因为二次匹配对所有游标返回同一页而导致翻页无法推进。
+
+已知限制：Coinbase 的 start / end 与 Kraken 的 since 同时承担游标与当前时间两种角色，只能宽松匹配：
+  - Coinbase 区间任务中间各页的 start / end 只由 start_time 推算，按完整路径命中各自的录制；
+    以当前时间为终点的最后一页（未指定 end_time）与最近 K线请求按二次匹配回放该接口最近一次录制的响应
+  - Kraken 冷启动的 since 由当前时间推算，按二次匹配回放；之后的 since 取自上一次响应的 last，
+    回放顺序与录制一致时按完整路径命中
+"""
+
+import hashlib
+import json
+import logging
+import os
+from http.client import HTTPMessage
+from typing import Optional
+from urllib.error import URLError
+from urllib.parse import parse_qsl, urlencode, urlsplit
+
+logger = logging.getLogger("data-collector-plugin")
+
+RECORD_DIR = os.environ.get("DC_RECORD_DIR", "")
+REPLAY_DIR = os.environ.get("DC_REPLAY_DIR", "")
+
+# 随当前时间变化的 query 参数，回放时作为二次匹配的忽略项（Coinbase start / end 与 Kraken since 的限制见模块说明）
+_TIME_PARAMS = {"endTime", "since", "start", "end"}
+
+
+def enabled() -> bool:
+    """是否处于回放模式。"""
+    return bool(REPLAY_DIR)
+
+
+def load(domain: str, path: str):
+    """回放录制的响应，返回 (status, headers, data)；未找到录制时抛出 URLError。"""
+    for key in (_key(path), _key(_strip_time_params(path))):
+        fixture = _read(os.path.join(REPLAY_DIR, domain, f"{key}.json"))
+        if fixture is not None:
+            headers = HTTPMessage()
+            for name, value in fixture.get("headers") or []:
+                headers[name] = value
+            return fixture.get("status", 200), headers, fixture.get("data")
+    raise URLError(f"回放录制不存在: domain={domain}, path={path}")
+
+
+def save(domain: str, path: str, status: int, headers, data):
+    """录制一次响应。同时按去掉时间参数后的路径保存一份，供回放时二次匹配。"""
+    if not RECORD_DIR:
+        return
+    fixture = {
+        "domain": domain,
+        "path": path,
+        "status": status,
+        "headers": list(headers.items()) if headers is not None else [],
+        "data": data,
+    }
+    directory = os.path.join(RECORD_DIR, domain)
+    try:
+        os.makedirs(directory, exist_ok=True)
+        for key in {_key(path), _key(_strip_time_params(path))}:
+            with open(os.path.join(directory, f"{key}.json"), "w", encoding="utf-8") as f:
+                json.dump(fixture, f, ensure_ascii=False)
+    except OSError as e:
+        logger.warning(f"响应录制失败: domain={domain}, path={path}, error={e}")
+
+
+def _key(path: str) -> str:
+    return hashlib.sha1(path.encode()).hexdigest()[:16]
+
+
+def _strip_time_params(path: str) -> str:
+    parts = urlsplit(path)
+    if not parts.query:
+        return path
+    query = [(k, v) for k, v in parse_qsl(parts.query, keep_blank_values=True) if k not in _TIME_PARAMS]
+    return f"{parts.path}?{urlencode(query)}" if query else parts.path
+
+
+def _read(file_path: str) -> Optional[dict]:
+    try:
+        with open(file_path, encoding="utf-8") as f:
+            return json.load(f)
+    except FileNotFoundError:
+        return None
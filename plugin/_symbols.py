@@ -0,0 +1,105 @@
+"""
+交易对的统一写法
+
+各交易所的交易对写法不同（Binance BTCUSDT、OKX BTC-USDT-SWAP、Coinbase BTC-USD、Kraken XBTUSD），
+所有插件写入存储的 symbol / object_id 统一为内部规范写法 "BASE-QUOTE"（如 BTC-USDT），
+资产代码为大写并统一别名（XBT → BTC），保证不同交易所的同一交易对可以按 symbol 直接关联。
+
+symbol_filter 的 include_symbols / exclude_symbols 统一按 symbol_key()（去掉分隔符的规范写法，如 BTCUSDT）匹配，
+因此 "BTC-USDT"、"btc/usdt"、"BTCUSDT" 均可使用。
+"""
+
+import logging
+
+logger = logging.getLogger("data-collector-plugin")
+
+# 跨交易所通用的资产别名（交易所特有的代码映射见各交易所共享模块，如 _kraken.py）
+_ASSET_ALIASES = {
+    "XBT": "BTC",
+    "XDG": "DOGE",
+}
+
+# 无分隔符 symbol（BTCUSDT）拆分时识别的计价币种
+KNOWN_QUOTES = ("USDT", "USDC", "FDUSD", "TUSD", "BUSD", "USD", "EUR", "GBP", "BTC", "ETH")
+
+_SEPARATORS = ("/", "_")
+
+
+def normalize_asset(asset: str) -> str:
+    """资产代码统一为大写规范写法（xbt → BTC）。"""
+    asset = asset.strip().upper()
+    return _ASSET_ALIASES.get(asset, asset)
+
+
+def split_symbol(symbol: str, quotes: tuple = KNOWN_QUOTES) -> tuple[str, str]:
+    """拆分交易对为 (base, quote)，资产代码已规范化。
+
+    支持 BTC-USDT / BTC/USDT / btc_usdt / BTCUSDT；带合约后缀的写法（BTC-USDT-SWAP）只取前两段。
+    无分隔符时按 quotes（默认 KNOWN_QUOTES，交易所可传入自己的计价币种列表）匹配后缀，匹配到多个计价币种（如 BNBFDUSD 可拆为 BNB-FDUSD 或 BNBFD-USD）
+    时不做猜测，抛出 ValueError，需改用带分隔符的写法。
+    """
+    normalized = symbol.strip().upper()
+    for sep in _SEPARATORS:
+        normalized = normalized.replace(sep, "-")
+    parts = [p for p in normalized.split("-") if p]
+    if len(parts) >= 2:
+        return normalize_asset(parts[0]), normalize_asset(parts[1])
+    if len(parts) == 1:
+        candidates = [q for q in quotes if parts[0].endswith(q) and len(parts[0]) > len(q)]
+        if len(candidates) > 1:
+            options = " / ".join(f"{parts[0][:-len(q)]}-{q}" for q in candidates)
+            raise ValueError(f"交易对写法有歧义: {symbol}（可能为 {options}），请使用 BASE-QUOTE 写法")
+        if candidates:
+            quote = candidates[0]
+            return normalize_asset(parts[0][:-len(quote)]), quote
+    raise ValueError(f"无法识别计价币种: {symbol}")
+
+
+def canonical_symbol(base: str, quote: str) -> str:
+    """由 base / quote 资产生成规范写法（BTC-USDT）。"""
+    return f"{normalize_asset(base)}-{normalize_asset(quote)}"
+
+
+def symbol_key(symbol: str, split=split_symbol) -> str:
+    """去掉分隔符的规范写法（BTC-USDT / XBT/USDT → BTCUSDT），用于黑白名单匹配。
+
+    split 可替换为交易所特有的拆分函数（如 _kraken.split_symbol 额外识别 XETH / ZUSD）。
+    无法识别计价币种时退化为去掉分隔符后的大写原文。
+    """
+    try:
+        base, quote = split(symbol)
+    except ValueError:
+        key = symbol.strip().upper()
+        for sep in ("-",) + _SEPARATORS:
+            key = key.replace(sep, "")
+        return key
+    return f"{base}{quote}"
+
+
+def normalize_symbol_set(symbols, split=split_symbol) -> set[str]:
+    """将黑白名单统一为 symbol_key 集合。单个字符串视为只含一项的列表，其他类型记录 warning 并视为未配置。"""
+    if not symbols:
+        return set()
+    if isinstance(symbols, str):
+        symbols = [symbols]
+    elif not isinstance(symbols, (list, tuple)):
+        logger.warning(f"symbol_filter 黑白名单格式错误，已忽略: {symbols!r}")
+        return set()
+    return {symbol_key(str(sym), split) for sym in symbols}
+
+
+def filter_values(symbol_filter: dict, key: str, default: tuple) -> set[str]:
+    """读取 symbol_filter 中的字符串列表字段（quote_assets / statuses），未配置时使用 default。
+
+    单个字符串视为只含一项的列表（"USDT" → {"USDT"}，而不是拆成字符集合）；
+    其他类型或列表中含非字符串元素时记录 warning 并回退为 default。
+    """
+    value = symbol_filter.get(key)
+    if not value:
+        return set(default)
+    if isinstance(value, str):
+        return {value}
+    if isinstance(value, (list, tuple)) and all(isinstance(v, str) for v in value):
+        return set(value)
+    logger.warning(f"symbol_filter.{key} 格式错误，使用默认值 {list(default)}: {value!r}")
+    return set(default)
@@ -0,0 +1,95 @@
+"""
+K线时间的统一约定与转换
+
+各交易所 K线时间戳的单位与含义不同（Binance / OKX 为毫秒开盘时间，Coinbase / Kraken 为秒级开盘时间，
+Binance 另给出收盘时间），所有 K线插件统一通过 kline_times() 输出以下约定的时间字段，
+保证不同交易所的同一根 K线可以按时间直接对齐：
+
+  - 时区为 UTC，格式为 "YYYY-mm-dd HH:MM:SS"
+  - open_time 为周期左边界（1m 为整分钟、1h 为整点、1d 为 0 点、1M 为当月 1 日 0 点）
+  - close_time 为下一根 K线的 open_time 减 1 秒（如 1m: 00:00:00 → 00:00:59）
+"""
+
+from datetime import datetime, timezone
+from typing import Optional
+
+from _intervals import INTERVAL_MS
+
+TIME_FORMAT = "%Y-%m-%d %H:%M:%S"
+
+_DAY_MS = 86_400_000
+
+
+def format_ms(ms: int) -> str:
+    """毫秒时间戳 → UTC 时间字符串。"""
+    return datetime.fromtimestamp(ms / 1000, tz=timezone.utc).strftime(TIME_FORMAT)
+
+
+def format_ts(ts: int) -> str:
+    """秒级时间戳 → UTC 时间字符串。"""
+    return format_ms(ts * 1000)
+
+
+def parse_time_ms(value) -> Optional[int]:
+    """解析 task_params 中的时间：毫秒时间戳或 UTC 字符串 "YYYY-mm-dd HH:MM:SS"。空值返回 None。
+
+    无法解析时（包括 list / dict / bool 等类型错误）统一抛出 ValueError，由 parse_job 按参数错误处理。
+    """
+    if value is None or value == "":
+        return None
+    if isinstance(value, bool) or not isinstance(value, (int, float, str)):
+        raise ValueError(f"时间格式错误: {value!r}")
+    if isinstance(value, (int, float)):
+        try:
+            return int(value)
+        except (ValueError, OverflowError):
+            raise ValueError(f"时间格式错误: {value!r}")
+    try:
+        return int(value)
+    except ValueError:
+        pass
+    try:
+        dt = datetime.strptime(value, TIME_FORMAT).replace(tzinfo=timezone.utc)
+    except ValueError:
+        raise ValueError(f"时间格式错误: {value!r}")
+    return int(dt.timestamp() * 1000)
+
+
+def parse_time_range(params: dict) -> tuple[Optional[int], Optional[int]]:
+    """解析 task_params 中的 start_time / end_time（毫秒），同时指定时要求 start_time 早于 end_time。
+
+    Raises:
+        ValueError: 时间格式错误，或区间为空 / 倒置
+    """
+    start_ms = parse_time_ms(params.get("start_time"))
+    end_ms = parse_time_ms(params.get("end_time"))
+    if start_ms is not None and end_ms is not None and start_ms >= end_ms:
+        raise ValueError(f"时间范围无效: start_time={format_ms(start_ms)} 不早于 end_time={format_ms(end_ms)}")
+    return start_ms, end_ms
+
+
+def next_open_ms(open_ms: int, interval: str) -> int:
+    """下一根 K线的开盘时间（毫秒），1M 按自然月推进。"""
+    if interval == "1M":
+        opened = datetime.fromtimestamp(open_ms / 1000, tz=timezone.utc)
+        year, month = (opened.year + 1, 1) if opened.month == 12 else (opened.year, opened.month + 1)
+        return int(opened.replace(year=year, month=month).timestamp() * 1000)
+    return open_ms + INTERVAL_MS[interval]
+
+
+def kline_times(open_ms: int, interval: str) -> tuple[str, str]:
+    """按统一约定返回 (open_time, close_time) 字符串。"""
+    return format_ms(open_ms), format_ms(next_open_ms(open_ms, interval) - 1000)
+
+
+def is_aligned(open_ms: int, interval: str) -> bool:
+    """open_time 是否位于周期左边界。
+
+    3d / 1w 的起始日各交易所不同（如周线从周一或周四开始），只校验为 0 点；1M 校验为当月 1 日 0 点。
+    """
+    if interval == "1M":
+        opened = datetime.fromtimestamp(open_ms / 1000, tz=timezone.utc)
+        return opened.day == 1 and open_ms % _DAY_MS == 0
+    if interval in ("3d", "1w"):
+        return open_ms % _DAY_MS == 0
+    return open_ms % INTERVAL_MS[interval] == 0
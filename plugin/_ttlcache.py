@@ -0,0 +1,96 @@
+"""
+插件共享的 TTL 缓存
+
+用于缓存体积大、变化慢的交易所接口响应（如 Binance exchangeInfo），
+避免同一轮触发中的多个任务、以及相邻几轮触发重复下载同一份数据。
+
+同一 key 的并发加载会被合并：只有一个调用方真正请求接口，其余调用方等待并复用其结果。
+加载失败时不缓存，异常直接抛给调用方。
+"""
+
+import threading
+import time
+
+
+class TTLCache:
+    """按 key 缓存加载结果，超过 ttl_seconds 后下次访问时重新加载。线程安全。"""
+
+    def __init__(self, name: str, ttl_seconds: float):
+        self.name = name
+        self.ttl_seconds = ttl_seconds
+        self._entries: dict = {}
+        self._key_locks: dict = {}
+        self._lock = threading.Lock()
+        self._hits = 0
+        self._misses = 0
+        self._coalesced = 0
+
+    def get_or_load(self, key, loader):
+        """返回 key 对应的缓存值；缓存不存在或已过期时调用 loader() 加载并缓存。"""
+        with self._lock:
+            value, ok = self._get_fresh(key)
+            if ok:
+                self._hits += 1
+                return value
+            key_lock = self._key_locks.setdefault(key, threading.Lock())
+
+        with key_lock:
+            # 等锁期间其他调用方可能已完成加载
+            with self._lock:
+                value, ok = self._get_fresh(key)
+                if ok:
+                    self._coalesced += 1
+                    return value
+                self._misses += 1
+
+            value = loader()
+            with self._lock:
+                self._entries[key] = (time.monotonic() + self.ttl_seconds, value)
+            return value
+
+    def invalidate(self, key=None):
+        """清除指定 key（key 为 None 时清空全部）的缓存。"""
+        with self._lock:
+            if key is None:
+                self._entries.clear()
+            else:
+                self._entries.pop(key, None)
+
+    def stats(self) -> dict:
+        now = time.monotonic()
+        with self._lock:
+            return {
+                "ttl_seconds": self.ttl_seconds,
+                "entries": len(self._entries),
+                "fresh_entries": sum(1 for expires_at, _ in self._entries.values() if expires_at > now),
+                "hits_total": self._hits,
+                "misses_total": self._misses,
+                "coalesced_total": self._coalesced,
+            }
+
+    def _get_fresh(self, key):
+        entry = self._entries.get(key)
+        if entry is None or entry[0] <= time.monotonic():
+            return None, False
+        return entry[1], True
+
+
+_caches: dict[str, TTLCache] = {}
+_caches_lock = threading.Lock()
+
+
+def get_cache(name: str, ttl_seconds: float) -> TTLCache:
+    """按 name 获取（首次调用时创建）共享缓存，后续调用的参数以首次创建时为准。"""
+    with _caches_lock:
+        cache = _caches.get(name)
+        if cache is None:
+            cache = TTLCache(name, ttl_seconds)
+            _caches[name] = cache
+        return cache
+
+
+def cache_stats() -> dict[str, dict]:
+    """所有已创建缓存的指标快照，key 为缓存名称。"""
+    with _caches_lock:
+        caches = list(_caches.values())
+    return {cache.name: cache.stats() for cache in caches}
@@ -8,13 +8,15 @@ Binance K线采集插件
 
 import json
 import logging
-import ssl
-from concurrent.futures import ThreadPoolExecutor, as_completed
-from datetime import datetime, timezone
 from typing import Optional
-from urllib.request import Request, urlopen
 from urllib.error import URLError, HTTPError
-from urllib.parse import quote
+from urllib.parse import urlencode
+
+import _binance_http
+from _intervals import CANONICAL_INTERVALS, parse_interval
+from _klines import collect_kline_jobs, collect_range
+from _timeutil import kline_times, next_open_ms, parse_time_range
+from _symbols import canonical_symbol, split_symbol, symbol_key
 
 logger = logging.getLogger("data-collector-plugin")
 
@@ -32,8 +34,15 @@ _EXCHANGE_CONFIG = {
 
 _DATASET_IDS = {"SWAP": 100, "SPOT": 101}
 
-STATUS_SUCCESS = 2
-STATUS_FAILED = 4
+# 常规采集（limit < 100）的请求权重：现货 klines 固定为 2，合约 limit < 100 为 1
+_RECENT_WEIGHT = {"SPOT": 2, "SWAP": 1}
+
+# 区间采集单次请求的最大根数及对应权重（现货 limit=1000 权重 2，合约 limit=1000 权重 5）
+_PAGE_LIMIT = 1000
+_PAGE_WEIGHT = {"SPOT": 2, "SWAP": 5}
+
+# 区间任务单次触发的最大翻页数，与时间预算（见 _klines.py）任一到达即停止，下次触发继续
+_MAX_PAGES = 200
 
 # ============================================================================
 # 自注册
@@ -41,7 +50,7 @@ STATUS_FAILED = 4
 
 
 def parse_job(job_raw: dict) -> Optional[dict]:
-    """从 framework job 中提取 kline 采集所需参数。返回 None 表示跳过。"""
+    """从 framework job 中提取 kline 采集所需参数。返回 None 表示跳过，参数无效时抛出 ValueError。"""
     task = job_raw.get("task", {})
     task_id = task.get("task_id", "")
     task_params_raw = task.get("task_params", "")
@@ -51,26 +60,32 @@ def parse_job(job_raw: dict) -> Optional[dict]:
         logger.warning(f"[parse_job] task_params 解析失败: task_id={task_id}, raw={task_params_raw[:200]}")
         return None
 
+    # 参数无效时不静默跳过：ValueError 由 main.py 捕获并上报该 task 为 FAILED
     inst_type = params.get("inst_type", "")
     symbol = params.get("symbol", "")
-    if not inst_type or not symbol:
-        logger.warning(f"[parse_job] 缺少必要参数: task_id={task_id}, inst_type={inst_type!r}, symbol={symbol!r}")
-        return None
+    if not isinstance(inst_type, str) or inst_type not in _EXCHANGE_CONFIG:
+        raise ValueError(f"不支持的产品类型: inst_type={inst_type!r}, 可选: {sorted(_EXCHANGE_CONFIG)}")
+    if not isinstance(symbol, str) or not symbol:
+        raise ValueError(f"缺少必要参数或类型错误: symbol={symbol!r}")
 
-    interval = job_raw.get("interval", "")
-    if not interval:
-        logger.warning(f"[parse_job] interval 为空: task_id={task_id}, symbol={symbol}")
+    interval = parse_interval(job_raw.get("interval", ""), "binance")
+    symbol = _to_object_id(symbol)
+    start_ms, end_ms = parse_time_range(params)
 
     return {
         "task_id": task_id,
         "inst_type": inst_type,
         "symbol": symbol,
         "interval": interval,
+        "domain": _get_domain(inst_type),
+        "start_ms": start_ms,
+        "end_ms": end_ms,
+        "validate": params.get("validate_kline", True) is not False,
     }
 
 
 def collect_klines(jobs: list[dict], get_best_ip) -> dict:
-    """执行 kline 采集（并发）。
+    """执行 kline 采集（并发，流程见 _klines.collect_kline_jobs）。
 
     Args:
         jobs: 已解析的 job 列表，每个 job 为 parse_job 返回的 dict
@@ -79,95 +94,17 @@ def collect_klines(jobs: list[dict], get_best_ip) -> dict:
     Returns:
         {"task_results": [...], "write_groups": [...]}
     """
-    if not jobs:
-        return {"task_results": [], "write_groups": []}
-
-    symbols_desc = ", ".join(f"{j['symbol']}/{j['inst_type']}/{j['interval']}" for j in jobs)
-    logger.info(f"本轮 kline 采集: {len(jobs)} 个任务, 标的: [{symbols_desc}]")
-
-    task_errors: dict[str, list[str]] = {}
-    task_ids_seen: set[str] = set()
-    all_data_points = []
-
-    def _do_collect(job):
-        task_id = job["task_id"]
-        inst_type = job["inst_type"]
-        symbol = job["symbol"]
-        interval = job["interval"]
-        try:
-            domain = _get_domain(inst_type)
-            best_ip = get_best_ip(domain) if domain else None
-            klines = _fetch_klines(inst_type, symbol, interval, best_ip=best_ip)
-            data_points = _format_data_points(symbol, klines)
-            logger.info(f"采集成功: symbol={symbol}, instType={inst_type}, interval={interval}, count={len(klines)}")
-            return task_id, symbol, interval, True, None, data_points
-        except Exception as e:
-            logger.error(f"采集失败: symbol={symbol}, instType={inst_type}, interval={interval}, error={e}")
-            return task_id, symbol, interval, False, str(e), []
-
-    with ThreadPoolExecutor(max_workers=min(len(jobs), 10)) as executor:
-        futures = {executor.submit(_do_collect, job): job for job in jobs}
-        succeeded = []
-        failed = []
-        for future in as_completed(futures):
-            task_id, symbol, interval, ok, err_msg, data_points = future.result()
-            task_ids_seen.add(task_id)
-            if ok:
-                succeeded.append(f"{symbol}/{interval}")
-                all_data_points.extend(data_points)
-            else:
-                failed.append(f"{symbol}/{interval}({err_msg})")
-                if task_id not in task_errors:
-                    task_errors[task_id] = []
-                task_errors[task_id].append(f"{symbol}/{interval}: {err_msg}")
-
-    logger.info(f"本轮 kline 采集完成: 成功={len(succeeded)}, 失败={len(failed)}"
-                f", 成功标的=[{', '.join(succeeded)}]"
-                + (f", 失败标的=[{', '.join(failed)}]" if failed else ""))
-
-    # 构建 task_results
-    task_results = []
-    for task_id in task_ids_seen:
-        if task_id in task_errors:
-            task_results.append({
-                "task_id": task_id,
-                "status": STATUS_FAILED,
-                "result": "; ".join(task_errors[task_id]),
-            })
-        else:
-            task_results.append({
-                "task_id": task_id,
-                "status": STATUS_SUCCESS,
-                "result": "",
-            })
-
-    # 构建 write_groups
-    write_groups = []
-    if all_data_points and jobs:
-        first = jobs[0]
-        interval = first.get("interval", "1m")
-        if interval.endswith("h"):
-            freq = interval[:-1] + "H"
-        elif interval.endswith("d"):
-            freq = interval[:-1] + "D"
-        else:
-            freq = interval
-        write_groups.append({
-            "write_mode": "set_data",
-            "dataset_id": _DATASET_IDS.get(first["inst_type"], 100),
-            "freq": freq,
-            "data_points": all_data_points,
-        })
-
-    return {
-        "task_results": task_results,
-        "write_groups": write_groups,
-    }
+    return collect_kline_jobs(jobs, get_best_ip, COLLECTOR["data_type"], "Binance",
+                              _fetch, _format_data_points, _DATASET_IDS)
 
 
 COLLECTOR = {
     "data_type": "kline",
     "data_source": "binance",
+    "description": "Binance 现货/合约 K 线数据采集",
+    "inst_types": sorted(_EXCHANGE_CONFIG),
+    "intervals": list(CANONICAL_INTERVALS),
+    "rate_limit_keys": sorted(cfg[2] for cfg in _EXCHANGE_CONFIG.values()),
     "collect": collect_klines,
     "parse_job": parse_job,
 }
@@ -177,6 +114,34 @@ COLLECTOR = {
 # ============================================================================
 
 
+def _to_object_id(symbol: str) -> str:
+    """任务中的 symbol → 写入存储的 object_id（BTCUSDT / btc/usdt → BTC-USDT）。
+
+    Binance 请求本身使用不带分隔符的写法，计价币种不在已知列表中（BTCTRY）或拆分有歧义（BTCBUSD）时
+    不做猜测，按去掉分隔符的原文（BTCTRY）采集并写入。
+    """
+    try:
+        return canonical_symbol(*split_symbol(symbol))
+    except ValueError as e:
+        object_id = symbol_key(symbol)
+        logger.info(f"[parse_job] 交易对无法拆分为 BASE-QUOTE，按原文采集: symbol={symbol}, "
+                    f"object_id={object_id}, reason={e}")
+        return object_id
+
+
+def _fetch(job: dict, best_ip: Optional[str], deadline: float) -> tuple[list[dict], Optional[str]]:
+    """拉取单个 job 的 K线：指定 start_time 时按区间续传，否则拉取最近几根。"""
+    inst_type, symbol, interval = job["inst_type"], job["symbol"], job["interval"]
+    if job["start_ms"] is not None:
+        return collect_range(
+            ("binance", inst_type, symbol, interval, job["start_ms"], job["end_ms"]),
+            job["start_ms"], job["end_ms"],
+            lambda cursor, end: _fetch_page(inst_type, symbol, interval, cursor, end, best_ip=best_ip),
+            deadline, _MAX_PAGES,
+        )
+    return _fetch_klines(inst_type, symbol, interval, best_ip=best_ip), None
+
+
 def _fetch_klines(
     inst_type: str,
     symbol: str,
@@ -184,51 +149,76 @@ def _fetch_klines(
     limit: int = 5,
     best_ip: Optional[str] = None,
 ) -> list[dict]:
-    """从 Binance API 获取 K线数据。"""
+    """从 Binance API 获取最近 limit 根 K线。"""
+    raw = _request_klines(inst_type, symbol, interval, {"limit": limit}, best_ip=best_ip,
+                          weight=_RECENT_WEIGHT.get(inst_type, 1))
+    return [_parse_item(item, interval) for item in raw]
+
+
+def _fetch_page(
+    inst_type: str,
+    symbol: str,
+    interval: str,
+    cursor_ms: int,
+    end_ms: int,
+    best_ip: Optional[str] = None,
+) -> tuple[list[dict], int]:
+    """拉取 [cursor_ms, end_ms) 区间的一页 K线，返回 (按 open_time 升序的 K线, 下一页起点)。
+
+    Binance 单次最多返回 1000 根；满页时下一页从最后一根的下一根开盘时间开始，不满一页说明区间已拉取完。
+    """
+    raw = _request_klines(inst_type, symbol, interval, {
+        "startTime": cursor_ms,
+        "endTime": end_ms - 1,
+        "limit": _PAGE_LIMIT,
+    }, best_ip=best_ip, weight=_PAGE_WEIGHT.get(inst_type, 1))
+    klines = [_parse_item(item, interval) for item in raw]
+    if len(raw) < _PAGE_LIMIT:
+        return klines, end_ms
+    return klines, next_open_ms(int(raw[-1][0]), interval)
+
+
+def _request_klines(
+    inst_type: str,
+    symbol: str,
+    interval: str,
+    params: dict,
+    best_ip: Optional[str] = None,
+    weight: int = 1,
+) -> list:
+    """请求 Binance klines 接口，params 为 limit / startTime / endTime 等附加参数。"""
     cfg = _EXCHANGE_CONFIG.get(inst_type)
     if cfg is None:
         raise ValueError(f"不支持的产品类型: {inst_type}")
 
     base_url, api_path, domain = cfg
-    api_symbol = symbol.replace("-", "")
-    url = f"{base_url}{api_path}?symbol={quote(api_symbol)}&interval={quote(interval)}&limit={limit}"
-
-    req = Request(url)
-    req.add_header("User-Agent", "data-collector/1.0")
+    query = {"symbol": symbol.replace("-", ""), "interval": interval}
+    query.update(params)
+    path = f"{api_path}?{urlencode(query)}"
 
     try:
-        if best_ip:
-            url_with_ip = url.replace(f"https://{domain}", f"https://{best_ip}")
-            req = Request(url_with_ip)
-            req.add_header("Host", domain)
-            req.add_header("User-Agent", "data-collector/1.0")
-
-            ctx = ssl.create_default_context()
-            ctx.check_hostname = False
-            ctx.verify_mode = ssl.CERT_NONE
-            resp = urlopen(req, timeout=10, context=ctx)
-        else:
-            resp = urlopen(req, timeout=10)
-
-        raw = json.loads(resp.read().decode())
+        return _binance_http.get_json(base_url, path, domain, best_ip=best_ip, timeout=10, weight=weight)
     except (URLError, HTTPError) as e:
         logger.error(f"Binance API 请求失败: {e}")
         raise
 
-    klines = []
-    for item in raw:
-        klines.append({
-            "open_time": datetime.fromtimestamp(item[0] / 1000, tz=timezone.utc).strftime("%Y-%m-%d %H:%M:%S"),
-            "open": float(item[1]),
-            "high": float(item[2]),
-            "low": float(item[3]),
-            "close": float(item[4]),
-            "volume": float(item[5]),
-            "close_time": datetime.fromtimestamp(item[6] / 1000, tz=timezone.utc).strftime("%Y-%m-%d %H:%M:%S"),
-            "quote_volume": float(item[7]),
-            "trade_count": int(item[8]),
-        })
-    return klines
+
+def _parse_item(item: list, interval: str) -> dict:
+    """解析 Binance K线行: [openTime, o, h, l, c, v, closeTime, quoteVolume, trades, ...]。"""
+    # close_time 按统一约定由 open_time 推导（与 Binance 返回的 item[6] 截断到秒后一致）
+    open_time, close_time = kline_times(int(item[0]), interval)
+    return {
+        "open_ms": int(item[0]),
+        "open_time": open_time,
+        "open": float(item[1]),
+        "high": float(item[2]),
+        "low": float(item[3]),
+        "close": float(item[4]),
+        "volume": float(item[5]),
+        "close_time": close_time,
+        "quote_volume": float(item[7]),
+        "trade_count": int(item[8]),
+    }
 
 
 def _format_data_points(symbol: str, klines: list[dict]) -> list[dict]:
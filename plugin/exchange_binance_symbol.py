@@ -8,11 +8,14 @@ Binance Symbol（标的同步）采集插件
 
 import json
 import logging
-import ssl
+import os
 from typing import Optional
-from urllib.request import Request, urlopen
 from urllib.error import URLError, HTTPError
 
+import _binance_http
+from _ttlcache import get_cache
+from _symbols import canonical_symbol, filter_values, normalize_symbol_set, symbol_key
+
 logger = logging.getLogger("data-collector-plugin")
 
 # ============================================================================
@@ -29,9 +32,20 @@ _EXCHANGE_INFO_CONFIG = {
 
 _DATASET_IDS = {"SWAP": 100, "SPOT": 101}
 
+# ExchangeInfo 接口权重较高，按权重消耗令牌
+_EXCHANGE_INFO_WEIGHT = {"SPOT": 20, "SWAP": 1}
+
+# ExchangeInfo 响应缓存时间（秒），同一产品类型在有效期内的多个任务复用同一份数据；
+# 可通过环境变量 BINANCE_EXCHANGE_INFO_TTL 调整，设为 0 关闭缓存
+_EXCHANGE_INFO_TTL_SECONDS = float(os.environ.get("BINANCE_EXCHANGE_INFO_TTL", "300"))
+
 STATUS_SUCCESS = 2
 STATUS_FAILED = 4
 
+# 默认过滤条件（task_params 未配置 symbol_filter 时生效）
+_DEFAULT_QUOTE_ASSETS = ("USDT",)
+_DEFAULT_STATUSES = ("TRADING",)
+
 # ============================================================================
 # 自注册
 # ============================================================================
@@ -51,9 +65,15 @@ def parse_job(job_raw: dict) -> Optional[dict]:
     if not inst_type:
         return None
 
+    symbol_filter = params.get("symbol_filter") or {}
+    if not isinstance(symbol_filter, dict):
+        logger.warning(f"[parse_job] symbol_filter 格式错误，使用默认过滤条件: task_id={task_id}")
+        symbol_filter = {}
+
     return {
         "task_id": task_id,
         "inst_type": inst_type,
+        "symbol_filter": symbol_filter,
     }
 
 
@@ -84,7 +104,7 @@ def collect_symbols(jobs: list[dict], get_best_ip) -> dict:
             domain = _get_domain(inst_type)
             best_ip = get_best_ip(domain) if domain else None
             raw_symbols = _fetch_symbols(inst_type, best_ip=best_ip)
-            filtered = _filter_symbols(raw_symbols, inst_type)
+            filtered = _filter_symbols(raw_symbols, inst_type, job.get("symbol_filter"))
             data_points = _format_data_points(filtered)
             all_data_points.extend(data_points)
             logger.info(f"Symbol 采集成功: taskID={task_id}, instType={inst_type}, count={len(filtered)}")
@@ -119,6 +139,9 @@ def collect_symbols(jobs: list[dict], get_best_ip) -> dict:
 COLLECTOR = {
     "data_type": "symbol",
     "data_source": "binance",
+    "description": "Binance 交易对列表同步",
+    "inst_types": sorted(_EXCHANGE_INFO_CONFIG),
+    "rate_limit_keys": sorted(cfg[2] for cfg in _EXCHANGE_INFO_CONFIG.values()),
     "collect": collect_symbols,
     "parse_job": parse_job,
 }
@@ -129,32 +152,23 @@ COLLECTOR = {
 
 
 def _fetch_symbols(inst_type: str, best_ip: Optional[str] = None) -> list[dict]:
-    """从 Binance ExchangeInfo API 获取交易对列表。"""
-    cfg = _EXCHANGE_INFO_CONFIG.get(inst_type)
-    if cfg is None:
+    """获取交易对列表，优先使用缓存的 ExchangeInfo，并发请求同一产品类型时只下载一次。"""
+    if inst_type not in _EXCHANGE_INFO_CONFIG:
         raise ValueError(f"不支持的产品类型: {inst_type}")
+    if _EXCHANGE_INFO_TTL_SECONDS <= 0:
+        return _fetch_exchange_info(inst_type, best_ip=best_ip)
 
-    base_url, api_path, domain = cfg
-    url = f"{base_url}{api_path}"
+    cache = get_cache("binance_exchange_info", _EXCHANGE_INFO_TTL_SECONDS)
+    return cache.get_or_load(inst_type, lambda: _fetch_exchange_info(inst_type, best_ip=best_ip))
 
-    req = Request(url)
-    req.add_header("User-Agent", "data-collector/1.0")
+
+def _fetch_exchange_info(inst_type: str, best_ip: Optional[str] = None) -> list[dict]:
+    """从 Binance ExchangeInfo API 获取交易对列表。"""
+    base_url, api_path, domain = _EXCHANGE_INFO_CONFIG[inst_type]
 
     try:
-        if best_ip:
-            url_with_ip = url.replace(f"https://{domain}", f"https://{best_ip}")
-            req = Request(url_with_ip)
-            req.add_header("Host", domain)
-            req.add_header("User-Agent", "data-collector/1.0")
-
-            ctx = ssl.create_default_context()
-            ctx.check_hostname = False
-            ctx.verify_mode = ssl.CERT_NONE
-            resp = urlopen(req, timeout=30, context=ctx)
-        else:
-            resp = urlopen(req, timeout=30)
-
-        raw = json.loads(resp.read().decode())
+        raw = _binance_http.get_json(base_url, api_path, domain, best_ip=best_ip, timeout=30,
+                                     weight=_EXCHANGE_INFO_WEIGHT.get(inst_type, 1))
     except (URLError, HTTPError) as e:
         logger.error(f"Binance ExchangeInfo API 请求失败: inst_type={inst_type}, error={e}")
         raise
@@ -164,19 +178,29 @@ def _fetch_symbols(inst_type: str, best_ip: Optional[str] = None) -> list[dict]:
     return symbols
 
 
-def _filter_symbols(symbols: list[dict], inst_type: str) -> list[dict]:
+def _filter_symbols(symbols: list[dict], inst_type: str, symbol_filter: Optional[dict] = None) -> list[dict]:
     """过滤并标准化交易对。
 
-    过滤条件：
-      - status == "TRADING" 且 quoteAsset == "USDT"
+    过滤条件（均可通过 task_params.symbol_filter 覆盖，未配置时保持默认）：
+      - quote_assets: 允许的计价币种，默认 ["USDT"]
+      - statuses: 允许的交易状态，默认 ["TRADING"]
+      - include_symbols: 白名单，非空时仅保留其中的交易对
+      - exclude_symbols: 黑名单，命中即剔除
       - SWAP 额外要求 contractType == "PERPETUAL"
+    黑白名单按规范写法匹配（见 _symbols.py），"BTC-USDT"、"BTC/USDT" 与 "BTCUSDT" 均可。
     输出标准化格式: symbol 字段为 "BTC-USDT" 形式。
     """
+    symbol_filter = symbol_filter or {}
+    quote_assets = filter_values(symbol_filter, "quote_assets", _DEFAULT_QUOTE_ASSETS)
+    statuses = filter_values(symbol_filter, "statuses", _DEFAULT_STATUSES)
+    include_symbols = normalize_symbol_set(symbol_filter.get("include_symbols"))
+    exclude_symbols = normalize_symbol_set(symbol_filter.get("exclude_symbols"))
+
     result = []
     for s in symbols:
-        if s.get("status", "") != "TRADING":
+        if s.get("status", "") not in statuses:
             continue
-        if s.get("quoteAsset", "") != "USDT":
+        if s.get("quoteAsset", "") not in quote_assets:
             continue
         if inst_type == "SWAP" and s.get("contractType", "") != "PERPETUAL":
             continue
@@ -185,9 +209,17 @@ def _filter_symbols(symbols: list[dict], inst_type: str) -> list[dict]:
         if not base_asset:
             continue
 
-        result.append({"symbol": f"{base_asset}-{s['quoteAsset']}"})
+        key = symbol_key(canonical_symbol(base_asset, s["quoteAsset"]))
+        if include_symbols and key not in include_symbols:
+            continue
+        if key in exclude_symbols:
+            continue
+
+        result.append({"symbol": canonical_symbol(base_asset, s["quoteAsset"])})
 
     logger.info(f"Symbol 过滤完成: inst_type={inst_type}, "
+                f"quote_assets={sorted(quote_assets)}, statuses={sorted(statuses)}, "
+                f"include={len(include_symbols)}, exclude={len(exclude_symbols)}, "
                 f"before={len(symbols)}, after={len(result)}")
     return result
 
@@ -0,0 +1,244 @@
+"""
+Coinbase（Advanced Trade）K线采集插件
+
+负责从 Coinbase Advanced Trade 公共行情接口获取现货 K线数据。
+通过 COLLECTOR 自注册机制，由 main.py 自动发现并调度。
+采集结果以 DataPoint 格式返回，由 scf-framework 统一写入 xData。
+
+与 Binance 的差异：
+  - 交易对使用 product_id：BTC-USD（与内部 BASE-QUOTE 写法一致）
+  - 周期参数为固定枚举 granularity：ONE_MINUTE / ONE_HOUR / ONE_DAY ...
+  - 时间参数为秒级 unix 时间戳，单次请求最多返回 300 根，超出按时间窗口分页
+"""
+
+import json
+import logging
+import time
+from typing import Optional
+from urllib.error import URLError, HTTPError
+from urllib.parse import quote, urlencode
+
+import _http
+from _klines import collect_kline_jobs, collect_range
+from _intervals import parse_interval
+from _timeutil import kline_times, parse_time_range
+from _ratelimit import get_limiter
+from _symbols import split_symbol
+
+logger = logging.getLogger("data-collector-plugin")
+
+# ============================================================================
+# 配置
+# ============================================================================
+
+COINBASE_BASE = "https://api.coinbase.com"
+COINBASE_DOMAIN = "api.coinbase.com"
+
+_CANDLES_PATH = "/api/v3/brokerage/market/products/{product_id}/candles"
+_MAX_CANDLES_PER_REQUEST = 300
+
+# 区间任务单次触发的最大翻页数，与时间预算（见 _klines.py）任一到达即停止，下次触发继续
+_MAX_PAGES = 200
+
+_DATASET_IDS = {"SPOT": 301}
+
+# 公共行情接口限频 10 次/秒
+_RATE_LIMIT_RPM = 600
+_RATE_LIMIT_BURST = 10
+
+# 内部周期 → (Coinbase granularity, 周期秒数)
+_GRANULARITY_MAP = {
+    "1m": ("ONE_MINUTE", 60),
+    "5m": ("FIVE_MINUTE", 5 * 60),
+    "15m": ("FIFTEEN_MINUTE", 15 * 60),
+    "30m": ("THIRTY_MINUTE", 30 * 60),
+    "1h": ("ONE_HOUR", 3600),
+    "2h": ("TWO_HOUR", 2 * 3600),
+    "4h": ("FOUR_HOUR", 4 * 3600),
+    "6h": ("SIX_HOUR", 6 * 3600),
+    "1d": ("ONE_DAY", 86400),
+}
+
+# ============================================================================
+# 自注册
+# ============================================================================
+
+
+def parse_job(job_raw: dict) -> Optional[dict]:
+    """从 framework job 中提取 Coinbase kline 采集所需参数。返回 None 表示跳过，参数无效时抛出 ValueError。"""
+    task = job_raw.get("task", {})
+    task_id = task.get("task_id", "")
+    task_params_raw = task.get("task_params", "")
+    try:
+        params = json.loads(task_params_raw) if task_params_raw else {}
+    except (json.JSONDecodeError, TypeError):
+        logger.warning(f"[parse_job] task_params 解析失败: task_id={task_id}, raw={task_params_raw[:200]}")
+        return None
+
+    # 参数无效时不静默跳过：ValueError 由 main.py 捕获并上报该 task 为 FAILED
+    inst_type = params.get("inst_type", "SPOT")
+    symbol = params.get("symbol", "")
+    if not isinstance(inst_type, str) or inst_type not in _DATASET_IDS:
+        raise ValueError(f"不支持的产品类型: inst_type={inst_type!r}, 可选: {sorted(_DATASET_IDS)}")
+    if not isinstance(symbol, str) or not symbol:
+        raise ValueError(f"缺少必要参数或类型错误: symbol={symbol!r}")
+
+    interval = parse_interval(job_raw.get("interval", ""), "coinbase", _GRANULARITY_MAP)
+    product_id = to_product_id(symbol)
+    start_ms, end_ms = parse_time_range(params)
+
+    return {
+        "task_id": task_id,
+        "inst_type": inst_type,
+        "symbol": product_id,
+        "product_id": product_id,
+        "interval": interval,
+        "domain": COINBASE_DOMAIN,
+        "start_ms": start_ms,
+        "end_ms": end_ms,
+        "validate": params.get("validate_kline", True) is not False,
+    }
+
+
+def collect_coinbase_klines(jobs: list[dict], get_best_ip) -> dict:
+    """执行 Coinbase kline 采集（并发，流程见 _klines.collect_kline_jobs）。
+
+    Args:
+        jobs: 已解析的 job 列表，每个 job 为 parse_job 返回的 dict
+        get_best_ip: callable(domain) -> Optional[str]，获取最优 IP
+
+    Returns:
+        {"task_results": [...], "write_groups": [...]}
+    """
+    return collect_kline_jobs(jobs, get_best_ip, COLLECTOR["data_type"], "Coinbase",
+                              _fetch, _format_data_points, _DATASET_IDS)
+
+
+COLLECTOR = {
+    "data_type": "coinbase_kline",
+    "data_source": "coinbase",
+    "description": "Coinbase Advanced Trade 现货 K 线数据采集",
+    "inst_types": sorted(_DATASET_IDS),
+    "intervals": list(_GRANULARITY_MAP),
+    "rate_limit_keys": [COINBASE_DOMAIN],
+    "collect": collect_coinbase_klines,
+    "parse_job": parse_job,
+}
+
+# ============================================================================
+# symbol / interval 映射
+# ============================================================================
+
+
+def to_product_id(symbol: str) -> str:
+    """内部 symbol（BTC-USD / BTC/USD / BTCUSD）→ Coinbase product_id（BTC-USD）。"""
+    base, quote_ = split_symbol(symbol)
+    return f"{base}-{quote_}"
+
+
+def to_granularity(interval: str) -> str:
+    """内部周期 → Coinbase granularity。"""
+    if interval not in _GRANULARITY_MAP:
+        raise ValueError(f"不支持的周期: {interval}")
+    return _GRANULARITY_MAP[interval][0]
+
+# ============================================================================
+# 内部函数
+# ============================================================================
+
+
+def _fetch(job: dict, best_ip: Optional[str], deadline: float) -> tuple[list[dict], Optional[str]]:
+    """拉取单个 job 的 K线：指定 start_time 时按区间续传，否则拉取最近几根。"""
+    product_id, interval = job["product_id"], job["interval"]
+    if job["start_ms"] is not None:
+        return collect_range(
+            ("coinbase", product_id, interval, job["start_ms"], job["end_ms"]),
+            job["start_ms"], job["end_ms"],
+            lambda cursor, end: _fetch_page(product_id, interval, cursor, end, best_ip=best_ip),
+            deadline, _MAX_PAGES,
+        )
+    return _fetch_klines(product_id, interval, best_ip=best_ip), None
+
+
+def _fetch_klines(product_id: str, interval: str, limit: int = 5, best_ip: Optional[str] = None) -> list[dict]:
+    """获取最近 limit 根 K线（含当前未收盘的一根，按 open_time 升序返回）。"""
+    granularity, seconds = _GRANULARITY_MAP[interval]
+    end_ts = int(time.time())
+    start_ts = (end_ts // seconds - limit + 1) * seconds
+    candles = _request_candles(product_id, granularity, start_ts, end_ts, best_ip)
+    return [_parse_candle(candle, interval) for candle in sorted(candles, key=lambda c: int(c["start"]))]
+
+
+def _fetch_page(
+    product_id: str,
+    interval: str,
+    cursor_ms: int,
+    end_ms: int,
+    best_ip: Optional[str] = None,
+) -> tuple[list[dict], int]:
+    """拉取 [cursor_ms, end_ms) 区间的一页 K线，返回 (按 open_time 升序的 K线, 下一页起点)。
+
+    Coinbase 单次最多返回 300 根，以 cursor 起 300 个周期为一个时间窗口顺序向后翻页。
+    """
+    granularity, seconds = _GRANULARITY_MAP[interval]
+    page_end = min(cursor_ms + _MAX_CANDLES_PER_REQUEST * seconds * 1000, end_ms)
+    # 时间参数为秒级，start 向上取整；end 按闭区间处理，取窗口终点前 1 秒，避免与下一页首根重叠
+    candles = _request_candles(product_id, granularity, -(-cursor_ms // 1000), (page_end - 1) // 1000, best_ip)
+    klines = [_parse_candle(candle, interval) for candle in candles]
+    klines = sorted((k for k in klines if cursor_ms <= k["open_ms"] < page_end), key=lambda k: k["open_ms"])
+    return klines, page_end
+
+
+def _request_candles(product_id: str, granularity: str, start_ts: int, end_ts: int,
+                     best_ip: Optional[str] = None) -> list[dict]:
+    """请求 Coinbase candles 接口，返回 candles 数组（Coinbase 按时间倒序返回）。"""
+    get_limiter(COINBASE_DOMAIN, _RATE_LIMIT_RPM, _RATE_LIMIT_BURST).acquire()
+
+    path = _CANDLES_PATH.format(product_id=quote(product_id)) + "?" + urlencode({
+        "start": start_ts,
+        "end": end_ts,
+        "granularity": granularity,
+        "limit": _MAX_CANDLES_PER_REQUEST,
+    })
+    try:
+        raw = _http.get_json(COINBASE_BASE, path, COINBASE_DOMAIN, best_ip=best_ip, timeout=10)
+    except (URLError, HTTPError) as e:
+        logger.error(f"Coinbase API 请求失败: product_id={product_id}, error={e}")
+        raise
+    return raw.get("candles") or []
+
+
+def _parse_candle(candle: dict, interval: str) -> dict:
+    """解析 Coinbase candle: {start, low, high, open, close, volume}（start 为秒级时间戳）。"""
+    open_ms = int(candle["start"]) * 1000
+    open_time, close_time = kline_times(open_ms, interval)
+    return {
+        "open_ms": open_ms,
+        "open_time": open_time,
+        "open": float(candle["open"]),
+        "high": float(candle["high"]),
+        "low": float(candle["low"]),
+        "close": float(candle["close"]),
+        "volume": float(candle["volume"]),
+        "close_time": close_time,
+    }
+
+
+def _format_data_points(symbol: str, klines: list[dict]) -> list[dict]:
+    """将 K线数据转为框架 DataPoint 格式。"""
+    data_points = []
+    for kline in klines:
+        data_points.append({
+            "times": kline["open_time"],
+            "object_id": symbol,
+            "fields": {
+                "candle_begin_time": kline["open_time"],
+                "candle_end_time": kline["close_time"],
+                "open": kline["open"],
+                "high": kline["high"],
+                "low": kline["low"],
+                "close": kline["close"],
+                "volume": kline["volume"],
+            },
+        })
+    return data_points
@@ -0,0 +1,227 @@
+"""
+Kraken K线采集插件
+
+负责从 Kraken 公共 OHLC 接口获取现货 K线数据。
+通过 COLLECTOR 自注册机制，由 main.py 自动发现并调度。
+采集结果以 DataPoint 格式返回，由 scf-framework 统一写入 xData。
+
+与 Binance 的差异：
+  - 资产代码特殊（XBT 即 BTC，交易对代码如 XXBTZUSD），与内部 BTC-USD 写法的映射见 _kraken.py
+  - 周期参数 interval 为分钟数：1 / 5 / 15 / 30 / 60 / 240 / 1440 / 10080
+  - OHLC 接口最多返回最近 720 根，不支持向前翻页；响应中的 last 游标用于增量轮询：
+    同一实例内下一轮以 last 作为 since，只拉取上次已收盘 K线之后的数据（冷启动时回退为最近 limit 根）
+"""
+
+import json
+import logging
+import threading
+import time
+from typing import Optional
+from urllib.parse import urlencode
+
+import _kraken
+from _klines import collect_kline_jobs
+from _intervals import parse_interval
+from _timeutil import format_ts, kline_times, parse_time_range
+
+logger = logging.getLogger("data-collector-plugin")
+
+# ============================================================================
+# 配置
+# ============================================================================
+
+_OHLC_PATH = "/0/public/OHLC"
+
+_DATASET_IDS = {"SPOT": 401}
+
+# 内部周期 → Kraken interval（分钟）
+_INTERVAL_MINUTES = {
+    "1m": 1,
+    "5m": 5,
+    "15m": 15,
+    "30m": 30,
+    "1h": 60,
+    "4h": 240,
+    "1d": 1440,
+    "1w": 10080,
+}
+
+# 增量轮询游标：(pair, interval) → 上一轮响应的 last，仅在当前实例内存中保存
+_cursors: dict[tuple, int] = {}
+_cursors_lock = threading.Lock()
+
+# ============================================================================
+# 自注册
+# ============================================================================
+
+
+def parse_job(job_raw: dict) -> Optional[dict]:
+    """从 framework job 中提取 Kraken kline 采集所需参数。返回 None 表示跳过，参数无效时抛出 ValueError。"""
+    task = job_raw.get("task", {})
+    task_id = task.get("task_id", "")
+    task_params_raw = task.get("task_params", "")
+    try:
+        params = json.loads(task_params_raw) if task_params_raw else {}
+    except (json.JSONDecodeError, TypeError):
+        logger.warning(f"[parse_job] task_params 解析失败: task_id={task_id}, raw={task_params_raw[:200]}")
+        return None
+
+    # 参数无效时不静默跳过：ValueError 由 main.py 捕获并上报该 task 为 FAILED
+    inst_type = params.get("inst_type", "SPOT")
+    symbol = params.get("symbol", "")
+    if not isinstance(inst_type, str) or inst_type not in _DATASET_IDS:
+        raise ValueError(f"不支持的产品类型: inst_type={inst_type!r}, 可选: {sorted(_DATASET_IDS)}")
+    if not isinstance(symbol, str) or not symbol:
+        raise ValueError(f"缺少必要参数或类型错误: symbol={symbol!r}")
+
+    interval = parse_interval(job_raw.get("interval", ""), "kraken", _INTERVAL_MINUTES)
+    base, quote = _kraken.split_symbol(symbol)
+    start_ms, end_ms = parse_time_range(params)
+
+    return {
+        "task_id": task_id,
+        "inst_type": inst_type,
+        "symbol": f"{base}-{quote}",
+        "pair": _kraken.to_pair(symbol),
+        "interval": interval,
+        "domain": _kraken.KRAKEN_DOMAIN,
+        "start_ms": start_ms,
+        "end_ms": end_ms,
+        "validate": params.get("validate_kline", True) is not False,
+    }
+
+
+def collect_kraken_klines(jobs: list[dict], get_best_ip) -> dict:
+    """执行 Kraken kline 采集（并发，流程见 _klines.collect_kline_jobs）。
+
+    Args:
+        jobs: 已解析的 job 列表，每个 job 为 parse_job 返回的 dict
+        get_best_ip: callable(domain) -> Optional[str]，获取最优 IP
+
+    Returns:
+        {"task_results": [...], "write_groups": [...]}
+    """
+    return collect_kline_jobs(jobs, get_best_ip, COLLECTOR["data_type"], "Kraken",
+                              _fetch, _format_data_points, _DATASET_IDS)
+
+
+COLLECTOR = {
+    "data_type": "kraken_kline",
+    "data_source": "kraken",
+    "description": "Kraken 现货 K 线数据采集",
+    "inst_types": sorted(_DATASET_IDS),
+    "intervals": list(_INTERVAL_MINUTES),
+    "rate_limit_keys": [_kraken.KRAKEN_DOMAIN],
+    "collect": collect_kraken_klines,
+    "parse_job": parse_job,
+}
+
+# ============================================================================
+# 内部函数
+# ============================================================================
+
+
+def _fetch(job: dict, best_ip: Optional[str], deadline: float) -> tuple[list[dict], Optional[str]]:
+    """拉取单个 job 的 K线：指定 start_time 时拉取区间（单次请求，不翻页），否则增量拉取。"""
+    if job["start_ms"] is not None:
+        end_ts = job["end_ms"] // 1000 if job["end_ms"] is not None else None
+        return _fetch_klines_range(job["pair"], job["interval"], job["start_ms"] // 1000, end_ts, best_ip=best_ip), None
+    return _fetch_klines(job["pair"], job["interval"], best_ip=best_ip), None
+
+
+def _fetch_klines(pair: str, interval: str, limit: int = 5, best_ip: Optional[str] = None) -> list[dict]:
+    """增量获取 K线（按 open_time 升序返回，含当前未收盘的一根）。
+
+    有上一轮的 last 游标时从游标之后开始拉取，可补齐触发间隔内错过的 K线；
+    否则拉取最近 limit 根。
+    """
+    seconds = _INTERVAL_MINUTES[interval] * 60
+    since = (int(time.time()) // seconds - limit) * seconds
+    with _cursors_lock:
+        cursor = _cursors.get((pair, interval))
+    if cursor is not None:
+        since = cursor
+
+    rows, last = _request_ohlc(pair, interval, since, best_ip)
+    if last:
+        with _cursors_lock:
+            _cursors[(pair, interval)] = last
+    return [_parse_row(row, interval) for row in rows]
+
+
+def _fetch_klines_range(
+    pair: str,
+    interval: str,
+    start_ts: int,
+    end_ts: Optional[int] = None,
+    best_ip: Optional[str] = None,
+) -> list[dict]:
+    """获取 [start_ts, end_ts) 区间内的 K线（按 open_time 升序返回）。
+
+    Kraken 只提供最近 720 根，区间起点早于可用数据时只返回能取到的部分并记录 warning。
+    """
+    if end_ts is None:
+        end_ts = int(time.time())
+    if start_ts >= end_ts:
+        raise ValueError(f"时间范围无效: start={start_ts}, end={end_ts}")
+
+    seconds = _INTERVAL_MINUTES[interval] * 60
+    rows, _ = _request_ohlc(pair, interval, start_ts - seconds, best_ip)
+    if rows and int(rows[0][0]) > start_ts:
+        logger.warning(f"Kraken 仅提供最近 720 根 K线，区间起点早于可用数据: pair={pair}, interval={interval}, "
+                       f"start={format_ts(start_ts)}, earliest={format_ts(int(rows[0][0]))}")
+    return [_parse_row(row, interval) for row in rows if start_ts <= int(row[0]) < end_ts]
+
+
+def _request_ohlc(pair: str, interval: str, since: int, best_ip: Optional[str] = None) -> tuple[list, int]:
+    """请求 Kraken OHLC 接口，返回 (按时间升序的 K线数组, last 游标)。
+
+    result 中除 last 外只有一个 key，为交易对的完整代码（如 XXBTZUSD）。
+    """
+    path = f"{_OHLC_PATH}?" + urlencode({
+        "pair": pair,
+        "interval": _INTERVAL_MINUTES[interval],
+        "since": since,
+    })
+    result = _kraken.get_result(path, best_ip=best_ip, timeout=10)
+    last = int(result.get("last") or 0)
+    rows = next((value for key, value in result.items() if key != "last"), [])
+    return sorted(rows, key=lambda row: int(row[0])), last
+
+
+def _parse_row(row: list, interval: str) -> dict:
+    """解析 Kraken OHLC 行: [time, open, high, low, close, vwap, volume, count]（time 为秒级时间戳）。"""
+    open_ms = int(row[0]) * 1000
+    open_time, close_time = kline_times(open_ms, interval)
+    return {
+        "open_ms": open_ms,
+        "open_time": open_time,
+        "open": float(row[1]),
+        "high": float(row[2]),
+        "low": float(row[3]),
+        "close": float(row[4]),
+        "volume": float(row[6]),
+        "close_time": close_time,
+        "trade_count": int(row[7]),
+    }
+
+
+def _format_data_points(symbol: str, klines: list[dict]) -> list[dict]:
+    """将 K线数据转为框架 DataPoint 格式。"""
+    data_points = []
+    for kline in klines:
+        data_points.append({
+            "times": kline["open_time"],
+            "object_id": symbol,
+            "fields": {
+                "candle_begin_time": kline["open_time"],
+                "candle_end_time": kline["close_time"],
+                "open": kline["open"],
+                "high": kline["high"],
+                "low": kline["low"],
+                "close": kline["close"],
+                "volume": kline["volume"],
+                "trade_num": kline["trade_count"],
+            },
+        })
+    return data_points
@@ -0,0 +1,183 @@
+"""
+Kraken Symbol（标的同步）采集插件
+
+负责从 Kraken 公共 AssetPairs 接口获取现货交易对列表，
+过滤后以 DataPoint 格式返回，由 scf-framework 统一写入 xData（UpsertObject）。
+通过 COLLECTOR 自注册机制，由 main.py 自动发现并调度。
+"""
+
+import json
+import logging
+from typing import Optional
+
+import _kraken
+from _symbols import canonical_symbol, filter_values, normalize_symbol_set
+
+logger = logging.getLogger("data-collector-plugin")
+
+# ============================================================================
+# 配置
+# ============================================================================
+
+_ASSET_PAIRS_PATH = "/0/public/AssetPairs"
+
+_DATASET_IDS = {"SPOT": 401}
+
+# 默认过滤条件（task_params 未配置 symbol_filter 时生效）
+_DEFAULT_QUOTE_ASSETS = ("USD", "USDT")
+_DEFAULT_STATUSES = ("online",)
+
+STATUS_SUCCESS = 2
+STATUS_FAILED = 4
+
+# ============================================================================
+# 自注册
+# ============================================================================
+
+
+def parse_job(job_raw: dict) -> Optional[dict]:
+    """从 framework job 中提取 Kraken symbol 采集所需参数。返回 None 表示跳过。"""
+    task = job_raw.get("task", {})
+    task_id = task.get("task_id", "")
+    task_params_raw = task.get("task_params", "")
+    try:
+        params = json.loads(task_params_raw) if task_params_raw else {}
+    except (json.JSONDecodeError, TypeError):
+        return None
+
+    inst_type = params.get("inst_type", "SPOT")
+    if inst_type not in _DATASET_IDS:
+        logger.warning(f"[parse_job] 不支持的产品类型: task_id={task_id}, inst_type={inst_type!r}")
+        return None
+
+    symbol_filter = params.get("symbol_filter") or {}
+    if not isinstance(symbol_filter, dict):
+        logger.warning(f"[parse_job] symbol_filter 格式错误，使用默认过滤条件: task_id={task_id}")
+        symbol_filter = {}
+
+    return {
+        "task_id": task_id,
+        "inst_type": inst_type,
+        "symbol_filter": symbol_filter,
+    }
+
+
+def collect_kraken_symbols(jobs: list[dict], get_best_ip) -> dict:
+    """执行 Kraken symbol 采集（串行）。
+
+    Args:
+        jobs: 已解析的 job 列表，每个 job 为 parse_job 返回的 dict
+        get_best_ip: callable(domain) -> Optional[str]，获取最优 IP
+
+    Returns:
+        {"task_results": [...], "write_groups": [...]}
+    """
+    if not jobs:
+        return {"task_results": [], "write_groups": []}
+
+    logger.info(f"本轮 Kraken symbol 采集: {len(jobs)} 个任务")
+
+    task_results = []
+    all_data_points = []
+
+    for job in jobs:
+        task_id = job["task_id"]
+        inst_type = job["inst_type"]
+        try:
+            pairs = _fetch_asset_pairs(best_ip=get_best_ip(_kraken.KRAKEN_DOMAIN))
+            filtered = _filter_pairs(pairs, job.get("symbol_filter"))
+            all_data_points.extend(_format_data_points(filtered))
+            logger.info(f"Kraken symbol 采集成功: taskID={task_id}, instType={inst_type}, count={len(filtered)}")
+            task_results.append({"task_id": task_id, "status": STATUS_SUCCESS, "result": ""})
+        except Exception as e:
+            logger.error(f"Kraken symbol 采集失败: taskID={task_id}, instType={inst_type}, error={e}")
+            task_results.append({"task_id": task_id, "status": STATUS_FAILED, "result": str(e)})
+
+    write_groups = []
+    if all_data_points:
+        write_groups.append({
+            "write_mode": "upsert_object",
+            "dataset_id": _DATASET_IDS["SPOT"],
+            "app_key": "symbol-sync",
+            "data_points": all_data_points,
+        })
+
+    return {"task_results": task_results, "write_groups": write_groups}
+
+
+COLLECTOR = {
+    "data_type": "kraken_symbol",
+    "data_source": "kraken",
+    "description": "Kraken 现货交易对列表同步",
+    "inst_types": sorted(_DATASET_IDS),
+    "rate_limit_keys": [_kraken.KRAKEN_DOMAIN],
+    "collect": collect_kraken_symbols,
+    "parse_job": parse_job,
+}
+
+# ============================================================================
+# 内部函数
+# ============================================================================
+
+
+def _fetch_asset_pairs(best_ip: Optional[str] = None) -> dict[str, dict]:
+    """从 Kraken AssetPairs 接口获取交易对，key 为交易对完整代码（如 XXBTZUSD）。"""
+    pairs = _kraken.get_result(_ASSET_PAIRS_PATH, best_ip=best_ip, timeout=30)
+    logger.info(f"Kraken AssetPairs 获取完成: total_pairs={len(pairs)}")
+    return pairs
+
+
+def _filter_pairs(pairs: dict[str, dict], symbol_filter: Optional[dict] = None) -> list[dict]:
+    """过滤并标准化交易对。
+
+    过滤条件（均可通过 task_params.symbol_filter 覆盖，与 Binance symbol 插件字段一致）：
+      - quote_assets: 允许的计价币种（内部写法），默认 ["USD", "USDT"]
+      - statuses: 允许的交易状态，默认 ["online"]
+      - include_symbols / exclude_symbols: 黑白名单按规范写法匹配（见 _symbols.py），"BTC-USD"、"BTCUSD" 与 "XBTUSD" 均可
+      - 始终剔除暗池交易对（altname 以 .d 结尾）
+    输出标准化格式: symbol 字段为 "BTC-USD" 形式（资产代码已转换为内部写法）。
+    """
+    symbol_filter = symbol_filter or {}
+    quote_assets = filter_values(symbol_filter, "quote_assets", _DEFAULT_QUOTE_ASSETS)
+    statuses = filter_values(symbol_filter, "statuses", _DEFAULT_STATUSES)
+    include_symbols = normalize_symbol_set(symbol_filter.get("include_symbols"), _kraken.split_symbol)
+    exclude_symbols = normalize_symbol_set(symbol_filter.get("exclude_symbols"), _kraken.split_symbol)
+
+    result = []
+    for pair in pairs.values():
+        if pair.get("altname", "").endswith(".d"):
+            continue
+        if pair.get("status", "online") not in statuses:
+            continue
+        base_asset = _kraken.normalize_asset(pair.get("base", ""))
+        quote_asset = _kraken.normalize_asset(pair.get("quote", ""))
+        if not base_asset or quote_asset not in quote_assets:
+            continue
+
+        key = f"{base_asset}{quote_asset}"
+        if include_symbols and key not in include_symbols:
+            continue
+        if key in exclude_symbols:
+            continue
+
+        result.append({"symbol": canonical_symbol(base_asset, quote_asset)})
+
+    result.sort(key=lambda s: s["symbol"])
+    logger.info(f"Kraken symbol 过滤完成: quote_assets={sorted(quote_assets)}, statuses={sorted(statuses)}, "
+                f"before={len(pairs)}, after={len(result)}")
+    return result
+
+
+def _format_data_points(symbols: list[dict]) -> list[dict]:
+    """将 symbol 列表转为框架 DataPoint 格式。"""
+    data_points = []
+    for sym in symbols:
+        data_points.append({
+            "times": "",
+            "object_id": sym["symbol"],
+            "fields": {
+                "symbol": sym["symbol"],
+                "unshelve_time": "2099-01-01 00:00:00",
+            },
+        })
+    return data_points
@@ -0,0 +1,312 @@
+"""
+OKX K线采集插件
+
+负责从 OKX V5 API 获取现货/永续/交割合约 K线数据。
+通过 COLLECTOR 自注册机制，由 main.py 自动发现并调度。
+采集结果以 DataPoint 格式返回，由 scf-framework 统一写入 xData。
+
+与 Binance 的差异：
+  - 交易对使用 instId：现货 BTC-USDT，永续 BTC-USDT-SWAP，交割 BTC-USDT-250328
+  - 周期参数为 bar：1m / 1H / 1Dutc ...（小时及以上大写，6H 及以上使用 UTC 对齐版本）
+  - 时间范围查询通过 after / before 游标限定时间窗口，单页最多 100 根
+"""
+
+import json
+import logging
+from typing import Optional
+from urllib.error import URLError, HTTPError
+from urllib.parse import urlencode
+
+import _http
+from _klines import collect_kline_jobs, collect_range
+from _intervals import INTERVAL_MS, parse_interval
+from _timeutil import kline_times, parse_time_range
+from _ratelimit import get_limiter
+from _symbols import canonical_symbol, split_symbol
+
+logger = logging.getLogger("data-collector-plugin")
+
+# ============================================================================
+# 配置
+# ============================================================================
+
+OKX_BASE = "https://www.okx.com"
+OKX_DOMAIN = "www.okx.com"
+
+# 最近 K线 / 历史 K线接口
+_CANDLES_PATH = "/api/v5/market/candles"
+_HISTORY_CANDLES_PATH = "/api/v5/market/history-candles"
+_PAGE_LIMIT = 100
+
+# 区间任务单次触发的最大翻页数，与时间预算（见 _klines.py）任一到达即停止，下次触发继续
+_MAX_PAGES = 200
+
+_DATASET_IDS = {"SWAP": 200, "SPOT": 201, "FUTURES": 202}
+
+# OKX 按接口限频：candles 40 次/2s，history-candles 20 次/2s
+_RATE_LIMITS = {
+    _CANDLES_PATH: (1200, 40),
+    _HISTORY_CANDLES_PATH: (600, 20),
+}
+
+# 内部周期 → OKX bar 参数
+_BAR_MAP = {
+    "1m": "1m",
+    "3m": "3m",
+    "5m": "5m",
+    "15m": "15m",
+    "30m": "30m",
+    "1h": "1H",
+    "2h": "2H",
+    "4h": "4H",
+    "6h": "6Hutc",
+    "12h": "12Hutc",
+    "1d": "1Dutc",
+    "1w": "1Wutc",
+    "1M": "1Mutc",
+}
+_INTERVAL_FROM_BAR = {bar: interval for interval, bar in _BAR_MAP.items()}
+
+# ============================================================================
+# 自注册
+# ============================================================================
+
+
+def parse_job(job_raw: dict) -> Optional[dict]:
+    """从 framework job 中提取 OKX kline 采集所需参数。返回 None 表示跳过，参数无效时抛出 ValueError。"""
+    task = job_raw.get("task", {})
+    task_id = task.get("task_id", "")
+    task_params_raw = task.get("task_params", "")
+    try:
+        params = json.loads(task_params_raw) if task_params_raw else {}
+    except (json.JSONDecodeError, TypeError):
+        logger.warning(f"[parse_job] task_params 解析失败: task_id={task_id}, raw={task_params_raw[:200]}")
+        return None
+
+    # 参数无效时不静默跳过：ValueError 由 main.py 捕获并上报该 task 为 FAILED
+    inst_type = params.get("inst_type", "")
+    symbol = params.get("symbol", "")
+    expiry = params.get("expiry", "")
+    if not isinstance(inst_type, str) or not isinstance(symbol, str) or not inst_type or not symbol:
+        raise ValueError(f"缺少必要参数或类型错误: inst_type={inst_type!r}, symbol={symbol!r}")
+    if not isinstance(expiry, str):
+        raise ValueError(f"expiry 类型错误: {expiry!r}")
+
+    interval = parse_interval(job_raw.get("interval", ""), "okx", _BAR_MAP)
+    inst_id = to_inst_id(symbol, inst_type, expiry)
+    start_ms, end_ms = parse_time_range(params)
+
+    return {
+        "task_id": task_id,
+        "inst_type": inst_type,
+        "symbol": to_object_id(inst_id),
+        "inst_id": inst_id,
+        "interval": interval,
+        "domain": OKX_DOMAIN,
+        "start_ms": start_ms,
+        "end_ms": end_ms,
+        "validate": params.get("validate_kline", True) is not False,
+    }
+
+
+def collect_okx_klines(jobs: list[dict], get_best_ip) -> dict:
+    """执行 OKX kline 采集（并发，流程见 _klines.collect_kline_jobs）。
+
+    Args:
+        jobs: 已解析的 job 列表，每个 job 为 parse_job 返回的 dict
+        get_best_ip: callable(domain) -> Optional[str]，获取最优 IP
+
+    Returns:
+        {"task_results": [...], "write_groups": [...]}
+    """
+    return collect_kline_jobs(jobs, get_best_ip, COLLECTOR["data_type"], "OKX",
+                              _fetch, _format_data_points, _DATASET_IDS)
+
+
+COLLECTOR = {
+    "data_type": "okx_kline",
+    "data_source": "okx",
+    "description": "OKX 现货/永续/交割合约 K 线数据采集",
+    "inst_types": sorted(_DATASET_IDS),
+    "intervals": list(_BAR_MAP),
+    "rate_limit_keys": [f"{OKX_DOMAIN}{path}" for path in _RATE_LIMITS],
+    "collect": collect_okx_klines,
+    "parse_job": parse_job,
+}
+
+# ============================================================================
+# symbol / interval 映射
+# ============================================================================
+
+
+def to_inst_id(symbol: str, inst_type: str, expiry: str = "") -> str:
+    """内部 symbol（BTC-USDT / BTCUSDT）→ OKX instId。
+
+    SPOT → BTC-USDT，SWAP → BTC-USDT-SWAP，FUTURES → BTC-USDT-{expiry}（如 250328）。
+    已经是完整 instId 的输入原样返回。
+    """
+    if len(symbol.split("-")) == 3:
+        return symbol.upper()
+    base, quote = split_symbol(symbol)
+
+    if inst_type == "SPOT":
+        return f"{base}-{quote}"
+    if inst_type == "SWAP":
+        return f"{base}-{quote}-SWAP"
+    if inst_type == "FUTURES":
+        if not expiry:
+            raise ValueError(f"FUTURES 需要指定 expiry: symbol={symbol}")
+        return f"{base}-{quote}-{expiry}"
+    raise ValueError(f"不支持的产品类型: {inst_type}")
+
+
+def from_inst_id(inst_id: str) -> str:
+    """OKX instId → 内部 symbol（BTC-USDT-SWAP / BTC-USDT-250328 → BTC-USDT）。"""
+    parts = inst_id.upper().split("-")
+    if len(parts) < 2:
+        raise ValueError(f"无法识别的 instId: {inst_id}")
+    return canonical_symbol(parts[0], parts[1])
+
+
+def to_object_id(inst_id: str) -> str:
+    """OKX instId → 写入存储的 object_id。
+
+    SPOT / SWAP 分属不同 dataset，统一为内部 symbol（BTC-USDT）；
+    FUTURES 同一标的有多个交割合约，保留交割日期（BTC-USD-250328），避免不同合约的 K线按同一 object_id 去重合并。
+    """
+    symbol = from_inst_id(inst_id)
+    parts = inst_id.upper().split("-")
+    if len(parts) == 3 and parts[2] != "SWAP":
+        return f"{symbol}-{parts[2]}"
+    return symbol
+
+
+def to_bar(interval: str) -> str:
+    """内部周期 → OKX bar 参数。"""
+    bar = _BAR_MAP.get(interval)
+    if bar is None:
+        raise ValueError(f"不支持的周期: {interval}")
+    return bar
+
+
+def from_bar(bar: str) -> str:
+    """OKX bar 参数 → 内部周期（兼容不带 utc 后缀的写法，如 1D / 6H）。"""
+    interval = _INTERVAL_FROM_BAR.get(bar) or _INTERVAL_FROM_BAR.get(f"{bar}utc")
+    if interval is None:
+        raise ValueError(f"不支持的 bar: {bar}")
+    return interval
+
+# ============================================================================
+# 内部函数
+# ============================================================================
+
+
+def _fetch(job: dict, best_ip: Optional[str], deadline: float) -> tuple[list[dict], Optional[str]]:
+    """拉取单个 job 的 K线：指定 start_time 时按区间续传，否则拉取最近几根。"""
+    inst_id, interval = job["inst_id"], job["interval"]
+    if job["start_ms"] is not None:
+        return collect_range(
+            ("okx", inst_id, interval, job["start_ms"], job["end_ms"]),
+            job["start_ms"], job["end_ms"],
+            lambda cursor, end: _fetch_page(inst_id, interval, cursor, end, best_ip=best_ip),
+            deadline, _MAX_PAGES,
+        )
+    return _fetch_klines(inst_id, interval, best_ip=best_ip), None
+
+
+def _fetch_klines(inst_id: str, interval: str, limit: int = 5, best_ip: Optional[str] = None) -> list[dict]:
+    """获取最近的 K线（按 open_time 升序返回）。"""
+    rows = _request_candles(_CANDLES_PATH, {"instId": inst_id, "bar": to_bar(interval), "limit": limit}, best_ip)
+    derivative = _is_derivative(inst_id)
+    return [_parse_row(row, interval, derivative) for row in reversed(rows)]
+
+
+def _fetch_page(
+    inst_id: str,
+    interval: str,
+    cursor_ms: int,
+    end_ms: int,
+    best_ip: Optional[str] = None,
+) -> tuple[list[dict], int]:
+    """拉取 [cursor_ms, end_ms) 区间的一页 K线，返回 (按 open_time 升序的 K线, 下一页起点)。
+
+    OKX 单页最多 100 根，以 cursor 起 100 个周期为一个时间窗口顺序向后翻页：
+    before=cursor-1、after=窗口终点，返回开盘时间位于两者之间的 K线（按时间倒序），下一页从窗口终点开始。
+    1M 按每月 28 天估算窗口，保证单个窗口不超过 100 根。
+    """
+    page_end = min(cursor_ms + INTERVAL_MS.get(interval, 28 * 86_400_000) * _PAGE_LIMIT, end_ms)
+    rows = _request_candles(
+        _HISTORY_CANDLES_PATH,
+        {"instId": inst_id, "bar": to_bar(interval), "before": cursor_ms - 1, "after": page_end, "limit": _PAGE_LIMIT},
+        best_ip,
+    )
+    derivative = _is_derivative(inst_id)
+    rows = sorted((row for row in rows if cursor_ms <= int(row[0]) < page_end), key=lambda row: int(row[0]))
+    return [_parse_row(row, interval, derivative) for row in rows], page_end
+
+
+def _request_candles(path: str, query: dict, best_ip: Optional[str] = None) -> list[list]:
+    """请求 OKX K线接口，返回 data 数组。"""
+    rpm, burst = _RATE_LIMITS[path]
+    get_limiter(f"{OKX_DOMAIN}{path}", rpm, burst).acquire()
+
+    try:
+        raw = _http.get_json(OKX_BASE, f"{path}?{urlencode(query)}", OKX_DOMAIN, best_ip=best_ip, timeout=10)
+    except (URLError, HTTPError) as e:
+        logger.error(f"OKX API 请求失败: {e}")
+        raise
+
+    if str(raw.get("code", "")) != "0":
+        raise RuntimeError(f"OKX API 返回错误: code={raw.get('code')}, msg={raw.get('msg')}")
+    return raw.get("data") or []
+
+
+def _is_derivative(inst_id: str) -> bool:
+    """instId 是否为永续 / 交割合约（BTC-USDT-SWAP / BTC-USD-250328）。"""
+    return len(inst_id.split("-")) == 3
+
+
+def _parse_row(row: list, interval: str, derivative: bool = False) -> dict:
+    """解析 OKX K线行: [ts, o, h, l, c, vol, volCcy, volCcyQuote, confirm]。
+
+    现货的 vol 为交易币数量；合约的 vol 为张数，交易币数量在 volCcy 中。
+    volume 统一取交易币数量（与 Binance 可比），合约张数另存为 contract_volume。
+    """
+    open_ms = int(row[0])
+    open_time, close_time = kline_times(open_ms, interval)
+    return {
+        "open_ms": open_ms,
+        "open_time": open_time,
+        "open": float(row[1]),
+        "high": float(row[2]),
+        "low": float(row[3]),
+        "close": float(row[4]),
+        "volume": float(row[6]) if derivative else float(row[5]),
+        "close_time": close_time,
+        "quote_volume": float(row[7]) if len(row) > 7 else 0.0,
+        "contract_volume": float(row[5]) if derivative else None,
+    }
+
+
+def _format_data_points(symbol: str, klines: list[dict]) -> list[dict]:
+    """将 K线数据转为框架 DataPoint 格式。"""
+    data_points = []
+    for kline in klines:
+        fields = {
+            "candle_begin_time": kline["open_time"],
+            "candle_end_time": kline["close_time"],
+            "open": kline["open"],
+            "high": kline["high"],
+            "low": kline["low"],
+            "close": kline["close"],
+            "volume": kline["volume"],
+            "quote_volume": kline["quote_volume"],
+        }
+        if kline["contract_volume"] is not None:
+            fields["contract_volume"] = kline["contract_volume"]
+        data_points.append({
+            "times": kline["open_time"],
+            "object_id": symbol,
+            "fields": fields,
+        })
+    return data_points
@@ -5,6 +5,7 @@ HTTP 服务（端口 9001），由 scf-framework 的 HTTPPluginAdapter 调用。
 
 接口：
   GET  /health       - 健康检查
+  GET  /collectors   - 已注册采集插件的描述信息（JSON）
   POST /on-trigger   - 接收触发事件（scheduled-collect）
 
 触发事件 payload 中包含 TaskStore 快照（tasks + tasks_md5），
@@ -21,7 +22,10 @@ import importlib
 import json
 import logging
 import os
+import signal
 import sys
+import threading
+import time
 from typing import Optional
 from http.server import HTTPServer, BaseHTTPRequestHandler
 
@@ -36,6 +40,13 @@ _FRAMEWORK_PYTHON_DIR = os.path.join(_PLUGIN_DIR, "..", "..", "scf-framework", "
 if os.path.isdir(_FRAMEWORK_PYTHON_DIR):
     sys.path.insert(0, os.path.abspath(_FRAMEWORK_PYTHON_DIR))
 
+import _replay
+from _binance_http import weight_stats
+from _klines import begin_trigger
+from _metrics import collector_metrics, metrics_summary, record_collect
+from _ratelimit import limiter_stats
+from _ttlcache import cache_stats
+
 logging.basicConfig(
     level=logging.INFO,
     format="%(asctime)s [%(levelname)s] %(message)s",
@@ -43,6 +54,8 @@ logging.basicConfig(
 )
 logger = logging.getLogger("data-collector-plugin")
 
+STATUS_FAILED = 4
+
 # ============================================================================
 # 模块级状态
 # ============================================================================
@@ -76,16 +89,41 @@ def main():
     except Exception as e:
         logger.warning(f"CLS 日志上报初始化失败（降级为仅控制台日志）: {e}")
 
-    # 自动发现并注册采集插件
-    _discover_collectors()
-
-    server = HTTPServer(("0.0.0.0", port), PluginHandler)
+    # 启动校验放在 try 内：校验失败时先记录错误，再由 finally flush 并关闭 CLS handler，保证启动错误能上报
+    server = None
     try:
+        # 自动发现并注册采集插件，并校验与 config.yaml 声明的采集类型一致
+        _discover_collectors()
+        _validate_supported_collectors(config_path)
+
+        if _replay.enabled():
+            logger.warning(f"回放模式已启用，不访问交易所，响应来自录制目录: {_replay.REPLAY_DIR}")
+        elif _replay.RECORD_DIR:
+            logger.info(f"响应录制已启用: {_replay.RECORD_DIR}")
+
+        server = HTTPServer(("0.0.0.0", port), PluginHandler)
+
+        # SIGTERM（实例回收 / 发布）时不再直接退出：等待当前请求（进行中的采集）处理完，
+        # 再走 finally 关闭 CLS handler，避免缓冲中的日志丢失。
+        # shutdown() 会阻塞到 serve_forever 退出，必须在其他线程中调用。
+        def _handle_sigterm(signum, frame):
+            logger.info("收到 SIGTERM，等待当前请求处理完成后退出")
+            threading.Thread(target=server.shutdown, daemon=True).start()
+
+        signal.signal(signal.SIGTERM, _handle_sigterm)
+
         server.serve_forever()
+        logger.info("插件服务停止")
     except KeyboardInterrupt:
         logger.info("插件服务停止")
-        server.shutdown()
+        if server is not None:
+            server.shutdown()
+    except Exception as e:
+        logger.error(f"插件异常退出: {e}")
+        raise
     finally:
+        if server is not None:
+            server.server_close()
         if _cls_handler is not None:
             logger.info("关闭 CLS 日志 handler，flush 剩余日志...")
             _cls_handler.close()
@@ -103,7 +141,18 @@ class PluginHandler(BaseHTTPRequestHandler):
             self.send_response(200)
             self.send_header("Content-Type", "application/json")
             self.end_headers()
-            self.wfile.write(json.dumps({"status": "ok"}).encode())
+            self.wfile.write(json.dumps({
+                "status": "ok",
+                "collectors": metrics_summary(),
+                "rate_limiters": limiter_stats(),
+                "binance_weight": weight_stats(),
+                "caches": cache_stats(),
+            }).encode())
+        elif self.path == "/collectors":
+            self.send_response(200)
+            self.send_header("Content-Type", "application/json")
+            self.end_headers()
+            self.wfile.write(json.dumps(export_collectors(), ensure_ascii=False, default=str).encode())
         else:
             self.send_response(404)
             self.end_headers()
@@ -156,6 +205,7 @@ def handle_trigger(event: dict) -> dict:
                 f"jobs_count={len(payload.get('jobs', []))}")
 
     if trigger_name == "scheduled-collect":
+        begin_trigger()
         return _dispatch_jobs(payload)
 
     logger.warning(f"未知触发器: {trigger_name}")
@@ -175,8 +225,9 @@ def _dispatch_jobs(payload: dict) -> dict:
         logger.warning("[_dispatch_jobs] jobs 为空，直接返回")
         return {"status": "ok"}
 
-    # 按 data_type 分组
+    # 按 data_type 分组；未注册的 data_type 直接上报 FAILED，避免任务在服务端一直无结果
     grouped: dict[str, list[dict]] = {}
+    unsupported_results: dict[str, dict] = {}
     for i, job_raw in enumerate(jobs_data):
         task = job_raw.get("task", {})
         task_params_raw = task.get("task_params", "")
@@ -188,14 +239,21 @@ def _dispatch_jobs(payload: dict) -> dict:
         except (json.JSONDecodeError, TypeError) as e:
             logger.warning(f"[_dispatch_jobs] job[{i}] task_params 解析失败: {e}, raw={task_params_raw[:200]}")
             continue
-        data_type = params.get("data_type", "")
-        if data_type not in _collector_registry:
-            logger.warning(f"[_dispatch_jobs] job[{i}] 跳过未注册的 data_type={data_type}, "
-                           f"已注册={list(_collector_registry.keys())}")
+        data_type = params.get("data_type", "") if isinstance(params, dict) else ""
+        if not isinstance(data_type, str) or data_type not in _collector_registry:
+            task_id = task.get("task_id", "")
+            logger.warning(f"[error.unsupported_collector] job[{i}] 未注册的 data_type={data_type!r}, "
+                           f"task_id={task_id}, 已注册={sorted(_collector_registry)}")
+            if task_id:
+                unsupported_results[task_id] = {
+                    "task_id": task_id,
+                    "status": STATUS_FAILED,
+                    "result": f"不支持的采集类型: data_type={data_type!r}, 支持={sorted(_collector_registry)}",
+                }
             continue
         grouped.setdefault(data_type, []).append(job_raw)
 
-    all_task_results = []
+    all_task_results = list(unsupported_results.values())
     write_groups = []
 
     logger.info(f"[_dispatch_jobs] 分组结果: { {k: len(v) for k, v in grouped.items()} }")
@@ -207,18 +265,42 @@ def _dispatch_jobs(payload: dict) -> dict:
 
         parsed_jobs = []
         for raw in raw_jobs:
-            parsed = parse_fn(raw) if parse_fn else raw
+            task_id = raw.get("task", {}).get("task_id", "")
+            # 参数无效（ValueError，如周期或交易对无法识别）或 parse_job 自身异常：
+            # 与未注册的 data_type 一样直接上报该 task 为 FAILED，不影响同一轮的其他 job
+            try:
+                parsed = parse_fn(raw) if parse_fn else raw
+            except ValueError as e:
+                logger.warning(f"[error.invalid_job] data_type={data_type}, task_id={task_id}, "
+                               f"interval={raw.get('interval')!r}, error={e}")
+                error = f"参数错误: {e}"
+            except Exception as e:
+                logger.error(f"[error.invalid_job] parse_job 异常: data_type={data_type}, task_id={task_id}, "
+                             f"error={e}", exc_info=True)
+                error = f"解析任务失败: {type(e).__name__}: {e}"
+            else:
+                error = None
+            if error is not None:
+                if task_id:
+                    all_task_results.append({"task_id": task_id, "status": STATUS_FAILED, "result": error})
+                continue
             if parsed is not None:
                 parsed_jobs.append(parsed)
             else:
                 logger.warning(f"[_dispatch_jobs] parse_job 返回 None, data_type={data_type}, "
-                               f"task_id={raw.get('task', {}).get('task_id', 'N/A')}")
+                               f"task_id={task_id or 'N/A'}")
 
         if not parsed_jobs:
             logger.warning(f"[_dispatch_jobs] data_type={data_type} 所有 job 解析后为空，跳过采集")
             continue
 
-        result = collect_fn(parsed_jobs, get_best_ip)
+        started = time.monotonic()
+        try:
+            result = collect_fn(parsed_jobs, get_best_ip)
+        except Exception as e:
+            record_collect(data_type, {}, time.monotonic() - started, error=str(e))
+            raise
+        record_collect(data_type, result, time.monotonic() - started)
         logger.info(f"[_dispatch_jobs] data_type={data_type} 采集结果: "
                     f"task_results={len(result.get('task_results', []))}, "
                     f"write_groups={len(result.get('write_groups', []))}")
@@ -227,20 +309,46 @@ def _dispatch_jobs(payload: dict) -> dict:
 
     response = {}
     if all_task_results:
-        response["task_results"] = all_task_results
+        response["task_results"] = _merge_task_results(all_task_results)
     if write_groups:
         response["write_groups"] = write_groups
     return response if response else {"status": "ok"}
 
 
+def _merge_task_results(task_results: list[dict]) -> list[dict]:
+    """合并同一 task_id 的多条结果：任一失败即整体失败，失败信息以分号拼接。
+
+    同一 task 的部分 job 在 parse_job 阶段失败、其余 job 由插件采集时，会产生多条结果。
+    """
+    merged: dict[str, dict] = {}
+    for r in task_results:
+        task_id = r["task_id"]
+        if task_id not in merged:
+            merged[task_id] = dict(r)
+            continue
+        m = merged[task_id]
+        if r["status"] != STATUS_FAILED:
+            continue
+        if m["status"] == STATUS_FAILED:
+            m["result"] = f"{m['result']}; {r['result']}"
+        else:
+            m.update(status=STATUS_FAILED, result=r["result"])
+    return list(merged.values())
+
+
 # ============================================================================
 # 插件自动发现
 # ============================================================================
 
 def _discover_collectors():
-    """扫描 plugin 目录下所有 py 文件，自动注册含 COLLECTOR 变量的模块。"""
+    """扫描 plugin 目录下所有 py 文件，自动注册含 COLLECTOR 变量的模块。
+
+    多个模块声明相同 data_type 时直接抛出 RuntimeError（启动失败），
+    避免后加载的插件静默覆盖先注册的插件。
+    """
     scanned = []
-    for filepath in glob.glob(os.path.join(_PLUGIN_DIR, "*.py")):
+    registered_by: dict[str, str] = {}
+    for filepath in sorted(glob.glob(os.path.join(_PLUGIN_DIR, "*.py"))):
         filename = os.path.basename(filepath)
         if filename == "main.py" or filename.startswith("_"):
             continue
@@ -249,17 +357,76 @@ def _discover_collectors():
         try:
             mod = importlib.import_module(module_name)
             collector = getattr(mod, "COLLECTOR", None)
-            if collector and isinstance(collector, dict) and "data_type" in collector:
-                data_type = collector["data_type"]
-                _collector_registry[data_type] = collector
-                logger.info(f"已注册采集插件: data_type={data_type}, module={module_name}")
-            else:
-                logger.info(f"模块 {module_name} 未定义 COLLECTOR，跳过")
         except Exception as e:
             logger.warning(f"加载插件模块 {module_name} 失败: {e}", exc_info=True)
+            continue
+
+        if not (collector and isinstance(collector, dict) and "data_type" in collector):
+            logger.info(f"模块 {module_name} 未定义 COLLECTOR，跳过")
+            continue
+        if not callable(collector.get("collect")):
+            logger.warning(f"模块 {module_name} 的 COLLECTOR 缺少可调用的 collect，跳过")
+            continue
+
+        data_type = collector["data_type"]
+        if data_type in registered_by:
+            raise RuntimeError(f"采集插件 data_type 重复注册: data_type={data_type}, "
+                               f"modules=[{registered_by[data_type]}, {module_name}]")
+        registered_by[data_type] = module_name
+        _collector_registry[data_type] = collector
+        logger.info(f"已注册采集插件: data_type={data_type}, module={module_name}")
     logger.info(f"插件扫描完成: 扫描模块={scanned}, 已注册={list(_collector_registry.keys())}")
 
 
+def _validate_supported_collectors(config_path: str):
+    """校验 config.yaml 中 plugin.supported_collectors 声明的每个类型都有已注册的插件。
+
+    Go 进程会把 supported_collectors 通过心跳上报给服务端，服务端据此分配任务；
+    若声明了但插件未注册，这些任务会在分发时被静默跳过，因此在启动阶段直接失败。
+    """
+    try:
+        import yaml
+    except ImportError:
+        raise RuntimeError("未安装 pyyaml，无法校验 supported_collectors（见 plugin/requirements.txt）")
+    try:
+        with open(config_path, encoding="utf-8") as f:
+            cfg = yaml.safe_load(f) or {}
+    except OSError as e:
+        logger.warning(f"读取配置文件失败，跳过 supported_collectors 校验: {e}")
+        return
+
+    supported = (cfg.get("plugin") or {}).get("supported_collectors") or []
+    missing = [data_type for data_type in supported if data_type not in _collector_registry]
+    if missing:
+        raise RuntimeError(f"supported_collectors 中的采集类型没有对应的插件: missing={missing}, "
+                           f"已注册={sorted(_collector_registry)}")
+    logger.info(f"supported_collectors 校验通过: {supported}")
+
+
+def export_collectors() -> dict:
+    """导出已注册采集插件的描述信息，供工具链和文档生成使用。
+
+    每个插件输出 COLLECTOR 中可 JSON 序列化的元数据字段（callable 字段除外），
+    并补充 module、has_parse_job、运行指标 metrics（尚未执行过为 null），
+    以及 rate_limit_keys 对应限流器的当前状态（限流器在首次请求时才创建，尚未请求过的 key 为 null）。
+    """
+    limiters = limiter_stats()
+    collectors = []
+    for data_type in sorted(_collector_registry):
+        collector = _collector_registry[data_type]
+        descriptor = {
+            key: value for key, value in collector.items()
+            if not callable(value)
+        }
+        descriptor["data_type"] = data_type
+        descriptor["module"] = getattr(collector.get("collect"), "__module__", "")
+        descriptor["has_parse_job"] = callable(collector.get("parse_job"))
+        descriptor["metrics"] = collector_metrics(data_type)
+        descriptor["rate_limits"] = {key: limiters.get(key) for key in collector.get("rate_limit_keys") or []}
+        collectors.append(descriptor)
+    return {"count": len(collectors), "collectors": collectors}
+
+
 # ============================================================================
 # 内部工具函数
 # ============================================================================
@@ -0,0 +1,21 @@
+"""
+插件单元测试
+
+运行方式（在仓库根目录）：
+
+    python3 -m unittest discover -s plugin/tests -t plugin
+
+tests/ 不在 main.py 的插件扫描范围内（只扫描 plugin/*.py），也不会被打进部署包（Makefile 只复制 plugin/*.py）。
+所有交易所请求都由 fakes.FakeExchange 应答，不访问网络。
+"""
+
+import logging
+import os
+import sys
+
+_PLUGIN_DIR = os.path.abspath(os.path.join(os.path.dirname(__file__), ".."))
+if _PLUGIN_DIR not in sys.path:
+    sys.path.insert(0, _PLUGIN_DIR)
+
+# 被测代码的日志量较大，测试中只保留断言输出
+logging.getLogger("data-collector-plugin").setLevel(logging.CRITICAL)
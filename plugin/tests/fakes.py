@@ -0,0 +1,176 @@
+"""
+测试共用的桩：模拟交易所 K线接口、可控时钟与任务构造
+"""
+
+import json
+import time
+from http.client import HTTPMessage
+from unittest import mock
+from urllib.error import HTTPError, URLError
+from urllib.parse import parse_qsl, urlsplit
+
+import _http
+import _klines
+import exchange_kraken_kline
+from _intervals import INTERVAL_MS
+
+_OKX_BARS = {"1m": "1m", "1H": "1h", "4H": "4h", "1Dutc": "1d"}
+_COINBASE_GRANULARITIES = {"ONE_MINUTE": "1m", "ONE_HOUR": "1h", "ONE_DAY": "1d"}
+_KRAKEN_PAIR_CODES = {"XBTUSD": "XXBTZUSD", "ETHUSD": "XETHZUSD"}
+_KRAKEN_INTERVALS = {"1": "1m", "60": "1h", "1440": "1d"}
+
+
+class FakeClock:
+    """可控时钟：替换模块中的 time，sleep 只推进时间不真正等待。"""
+
+    def __init__(self, now: float = 1_700_000_000.0):
+        self.now = now
+        self.slept = []
+
+    def time(self) -> float:
+        return self.now
+
+    def monotonic(self) -> float:
+        return self.now
+
+    def sleep(self, seconds: float):
+        self.slept.append(seconds)
+        # 真实 sleep 之后时间总会前进；极小的等待若小于浮点精度，时钟不前进会使被测代码反复重试
+        self.now += max(seconds, 1e-6)
+
+
+class FakeExchange:
+    """替换 _http.request_json，按请求参数生成 Binance / OKX / Coinbase / Kraken 的 K线响应。
+
+    K线价格由开盘时间决定，同一根 K线无论哪一页、哪个交易所返回都相同；
+    只生成 now_ms 之前开盘的 K线，calls 按顺序记录每次请求的 (domain, path, query)。
+    """
+
+    def __init__(self, now_ms: int = None):
+        self.now_ms = now_ms if now_ms is not None else int(time.time() * 1000)
+        self.calls = []
+        self.static = {}
+        self.fail = None
+        self._patch = mock.patch.object(_http, "request_json", self._request_json)
+
+    def __enter__(self):
+        self._patch.start()
+        return self
+
+    def __exit__(self, *exc):
+        self._patch.stop()
+
+    def queries(self, domain: str) -> list[dict]:
+        return [query for d, _, query in self.calls if d == domain]
+
+    def _request_json(self, base_url, path, domain, best_ip=None, timeout=10):
+        parts = urlsplit(path)
+        query = dict(parse_qsl(parts.query))
+        self.calls.append((domain, parts.path, query))
+        if self.fail is not None and self.fail(domain, parts.path, query):
+            raise URLError(f"fake failure: {domain}{path}")
+        if (domain, parts.path) in self.static:
+            return 200, HTTPMessage(), self.static[(domain, parts.path)]
+        if domain.endswith("binance.com"):
+            return 200, HTTPMessage(), self._binance(query)
+        if domain == "www.okx.com":
+            return 200, HTTPMessage(), self._okx(parts.path, query)
+        if domain == "api.coinbase.com":
+            return 200, HTTPMessage(), self._coinbase(path, query)
+        if domain == "api.kraken.com":
+            return 200, HTTPMessage(), self._kraken(query)
+        raise AssertionError(f"unexpected request: {domain}{path}")
+
+    def _binance(self, q: dict) -> list:
+        step = INTERVAL_MS[q["interval"]]
+        limit = int(q["limit"])
+        if "startTime" in q:
+            opens = self.opens(int(q["startTime"]), int(q["endTime"]), step)[:limit]
+        else:
+            opens = self.recent(step, limit)
+        rows = []
+        for t in opens:
+            o, h, l, c, v = bar(t, step)
+            rows.append([t, str(o), str(h), str(l), str(c), str(v), t + step - 1, str(v * c), 10])
+        return rows
+
+    def _okx(self, path: str, q: dict) -> dict:
+        step = INTERVAL_MS[_OKX_BARS[q["bar"]]]
+        limit = int(q.get("limit", 100))
+        if path.endswith("/history-candles"):
+            opens = self.opens(int(q["before"]) + 1, int(q["after"]) - 1, step)[-limit:]
+        else:
+            opens = self.recent(step, limit)
+        rows = []
+        for t in reversed(opens):
+            o, h, l, c, v = bar(t, step)
+            rows.append([str(t), str(o), str(h), str(l), str(c), str(v * 100), str(v), str(v * c), "1"])
+        return {"code": "0", "msg": "", "data": rows}
+
+    def _coinbase(self, path: str, q: dict) -> dict:
+        step = INTERVAL_MS[_COINBASE_GRANULARITIES[q["granularity"]]]
+        opens = self.opens(int(q["start"]) * 1000, int(q["end"]) * 1000, step)
+        if len(opens) > 300:
+            raise HTTPError(path, 400, "number of candles requested should be less than 350", HTTPMessage(), None)
+        candles = []
+        for t in reversed(opens):
+            o, h, l, c, v = bar(t, step)
+            candles.append({"start": str(t // 1000), "low": str(l), "high": str(h), "open": str(o),
+                            "close": str(c), "volume": str(v)})
+        return {"candles": candles}
+
+    def _kraken(self, q: dict) -> dict:
+        step = INTERVAL_MS[_KRAKEN_INTERVALS[q["interval"]]]
+        opens = self.opens(int(q["since"]) * 1000, self.now_ms, step)[-720:]
+        rows = []
+        for t in opens:
+            o, h, l, c, v = bar(t, step)
+            rows.append([t // 1000, str(o), str(h), str(l), str(c), str(c), str(v), 10])
+        # last 为最后一根已收盘 K线的开盘时间，下一轮以它为 since 增量拉取
+        last = (self.now_ms // step * step - step) // 1000
+        return {"error": [], "result": {_KRAKEN_PAIR_CODES.get(q["pair"], q["pair"]): rows, "last": last}}
+
+    def opens(self, lo_ms: int, hi_ms: int, step: int) -> list[int]:
+        """[lo_ms, hi_ms] 内、now_ms 之前开盘的 K线开盘时间。"""
+        first = -(-lo_ms // step) * step
+        return list(range(first, min(hi_ms, self.now_ms) + 1, step))
+
+    def recent(self, step: int, limit: int) -> list[int]:
+        last = self.now_ms // step * step
+        return [last - (limit - 1 - i) * step for i in range(limit)]
+
+
+def bar(open_ms: int, step: int) -> tuple:
+    """开盘时间 → (open, high, low, close, volume)，满足 OHLC 合法性。"""
+    price = 100 + (open_ms // step) % 50
+    return float(price), float(price + 2), float(price - 2), float(price + 1), float(1 + (open_ms // step) % 7)
+
+
+def make_job(task_id: str, data_type: str, interval: str = "1m", **params) -> dict:
+    """构造 /on-trigger payload 中的单个 job。"""
+    params["data_type"] = data_type
+    return {"task": {"task_id": task_id, "task_params": json.dumps(params)}, "interval": interval}
+
+
+def load_main():
+    """导入 main 并完成插件发现（只执行一次）。"""
+    import main
+    if not main._collector_registry:
+        main._discover_collectors()
+    return main
+
+
+def trigger(jobs: list[dict]) -> dict:
+    """按 scheduled-collect 触发一次采集，返回 /on-trigger 的响应。"""
+    return load_main().handle_trigger({"name": "scheduled-collect", "payload": {"jobs": jobs}})
+
+
+def results_by_task(response: dict) -> dict:
+    return {r["task_id"]: r for r in response.get("task_results", [])}
+
+
+def reset_kline_state():
+    """清空 K线插件的跨触发状态（区间进度、Kraken 游标、触发截止时间）。"""
+    _klines._range_cursors.clear()
+    _klines._trigger_deadline = None
+    exchange_kraken_kline._cursors.clear()
@@ -0,0 +1,111 @@
+import unittest
+from http.client import HTTPMessage
+from unittest import mock
+from urllib.error import HTTPError
+
+import _binance_http
+import _http
+import exchange_binance_kline
+from _ratelimit import RateLimitedError
+
+from .fakes import FakeClock
+
+# 未在 _WEIGHT_LIMITS 中的域名按默认限额 1200 计算，高水位为 960
+_DOMAIN = "weight-test.binance.com"
+
+
+def _headers(**values) -> HTTPMessage:
+    headers = HTTPMessage()
+    for name, value in values.items():
+        headers[name.replace("_", "-")] = str(value)
+    return headers
+
+
+class WeightThrottleTest(unittest.TestCase):
+    def setUp(self):
+        # 当前分钟窗口剩余 30 秒
+        self.clock = FakeClock(now=1_700_000_010.0)
+        for patcher in (mock.patch.object(_binance_http, "time", self.clock),
+                        mock.patch.dict(_binance_http._weight_states)):
+            patcher.start()
+            self.addCleanup(patcher.stop)
+
+    def _record(self, used: int, status: int = 200, **extra):
+        _binance_http._record_response_headers(_DOMAIN, _headers(X_MBX_USED_WEIGHT_1M=used, **extra), status)
+
+    def test_no_throttle_below_high_water(self):
+        self._record(900)
+        _binance_http._wait_before_request(_DOMAIN)
+        self.assertEqual(self.clock.slept, [])
+
+    def test_throttle_grows_with_rising_weight(self):
+        # 超出高水位的比例 × 窗口剩余时间，至少 0.1，最多 10 秒
+        for used, expected in ((984, 3.0), (1032, 9.0), (1200, 10.0)):
+            with self.subTest(used=used):
+                self.clock.slept.clear()
+                self._record(used)
+                _binance_http._wait_before_request(_DOMAIN)
+                self.assertEqual(len(self.clock.slept), 1)
+                self.assertAlmostEqual(self.clock.slept[0], expected)
+                self.clock.now = 1_700_000_010.0
+        self.assertEqual(_binance_http.weight_stats()[_DOMAIN]["throttled_total"], 3)
+
+    def test_weight_resets_in_next_window(self):
+        self._record(1200)
+        self.clock.now += 60
+        _binance_http._wait_before_request(_DOMAIN)
+        self.assertEqual(self.clock.slept, [])
+        self.assertEqual(_binance_http.weight_stats()[_DOMAIN]["used_weight_1m"], 0)
+
+    def test_retry_after_honored_exactly(self):
+        self._record(1200, status=429, Retry_After=3)
+        _binance_http._wait_before_request(_DOMAIN)
+        self.assertEqual(self.clock.slept, [3.0])
+        stats = _binance_http.weight_stats()[_DOMAIN]
+        self.assertEqual(stats["banned_total"], 1)
+        self.assertEqual(stats["banned_for_seconds"], 0)
+
+    def test_long_ban_fails_fast(self):
+        self._record(1200, status=418, Retry_After=30)
+        with self.assertRaises(RateLimitedError):
+            _binance_http._wait_before_request(_DOMAIN)
+        self.assertEqual(self.clock.slept, [])
+
+    def test_missing_retry_after_defaults_to_one_minute(self):
+        _binance_http._record_response_headers(_DOMAIN, _headers(), 429)
+        self.assertEqual(_binance_http.weight_stats()[_DOMAIN]["banned_for_seconds"], 60)
+
+    def test_get_json_reads_headers_and_429(self):
+        responses = [
+            (200, _headers(X_MBX_USED_WEIGHT_1M=100), []),
+            (200, _headers(X_MBX_USED_WEIGHT_1M=1032), []),
+            HTTPError("https://weight-test", 429, "Too Many Requests", _headers(Retry_After=2), None),
+        ]
+        with mock.patch.object(_http, "request_json", side_effect=responses):
+            _binance_http.get_json("https://weight-test", "/api/v3/klines", _DOMAIN)
+            self.assertEqual(self.clock.slept, [])
+            _binance_http.get_json("https://weight-test", "/api/v3/klines", _DOMAIN)
+            self.assertEqual(_binance_http.weight_stats()[_DOMAIN]["used_weight_1m"], 1032)
+            with self.assertRaises(HTTPError):
+                _binance_http.get_json("https://weight-test", "/api/v3/klines", _DOMAIN)
+        # 第三次请求前因权重超过高水位主动限速，收到 429 后封禁 2 秒
+        self.assertEqual(len(self.clock.slept), 1)
+        self.assertAlmostEqual(_binance_http.weight_stats()[_DOMAIN]["banned_for_seconds"], 2)
+
+
+class RequestWeightTest(unittest.TestCase):
+    def test_kline_requests_charge_endpoint_weight(self):
+        cases = [
+            (lambda: exchange_binance_kline._fetch_klines("SPOT", "BTC-USDT", "1m"), 2),
+            (lambda: exchange_binance_kline._fetch_klines("SWAP", "BTC-USDT", "1m"), 1),
+            (lambda: exchange_binance_kline._fetch_page("SPOT", "BTC-USDT", "1m", 0, 60_000), 2),
+            (lambda: exchange_binance_kline._fetch_page("SWAP", "BTC-USDT", "1m", 0, 60_000), 5),
+        ]
+        for fetch, weight in cases:
+            with mock.patch.object(_binance_http, "get_json", return_value=[]) as get_json:
+                fetch()
+            self.assertEqual(get_json.call_args.kwargs["weight"], weight)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,79 @@
+import unittest
+
+import exchange_coinbase_kline as coinbase
+from _timeutil import parse_time_ms
+
+from .fakes import FakeExchange, make_job, reset_kline_state, results_by_task, trigger
+
+# 录制的 candles 响应（Coinbase 按时间倒序返回，start 为秒级时间戳）
+_CANDLES = [
+    {"start": "1704070800", "low": "42250.0", "high": "42600.0", "open": "42475.2", "close": "42580.1",
+     "volume": "35.1"},
+    {"start": "1704067200", "low": "42261.0", "high": "42554.1", "open": "42283.6", "close": "42475.2",
+     "volume": "91.52"},
+]
+
+
+class CoinbaseMappingTest(unittest.TestCase):
+    def test_product_id(self):
+        for symbol in ("BTC-USD", "btc/usd", "BTCUSD", "XBT-USD"):
+            with self.subTest(symbol=symbol):
+                self.assertEqual(coinbase.to_product_id(symbol), "BTC-USD")
+
+    def test_granularity(self):
+        self.assertEqual([coinbase.to_granularity(i) for i in ("1m", "1h", "6h", "1d")],
+                         ["ONE_MINUTE", "ONE_HOUR", "SIX_HOUR", "ONE_DAY"])
+        with self.assertRaises(ValueError):
+            coinbase.to_granularity("1w")
+
+    def test_candle_fixture_to_data_points(self):
+        klines = sorted((coinbase._parse_candle(c, "1h") for c in _CANDLES), key=lambda k: k["open_ms"])
+        points = coinbase._format_data_points("BTC-USD", klines)
+        self.assertEqual(points[0], {
+            "times": "2024-01-01 00:00:00",
+            "object_id": "BTC-USD",
+            "fields": {
+                "candle_begin_time": "2024-01-01 00:00:00",
+                "candle_end_time": "2024-01-01 00:59:59",
+                "open": 42283.6,
+                "high": 42554.1,
+                "low": 42261.0,
+                "close": 42475.2,
+                "volume": 91.52,
+            },
+        })
+        self.assertEqual(points[1]["times"], "2024-01-01 01:00:00")
+
+
+class CoinbasePagingTest(unittest.TestCase):
+    def setUp(self):
+        reset_kline_state()
+
+    def test_range_split_at_candle_limit(self):
+        start = parse_time_ms("2024-01-01 00:00:00")
+        with FakeExchange() as fx:
+            response = trigger([make_job("t", "coinbase_kline", "ONE_MINUTE", symbol="BTC-USD",
+                                         start_time="2024-01-01 00:00:00", end_time="2024-01-01 11:40:00")])
+        self.assertEqual(results_by_task(response)["t"]["status"], 2)
+
+        # 700 根分 3 页（300 / 300 / 100），秒级 start / end 为闭区间，相邻两页不重叠
+        pages = [(int(q["start"]), int(q["end"])) for q in fx.queries("api.coinbase.com")]
+        s = start // 1000
+        self.assertEqual(pages, [(s, s + 17999), (s + 18000, s + 35999), (s + 36000, s + 41999)])
+
+        points = response["write_groups"][0]["data_points"]
+        self.assertEqual(len(points), 700)
+        self.assertEqual(len({p["times"] for p in points}), 700)
+        self.assertEqual(response["write_groups"][0]["dataset_id"], 301)
+
+    def test_recent_fetch(self):
+        with FakeExchange() as fx:
+            response = trigger([make_job("t", "coinbase_kline", "1h", symbol="BTC-USD")])
+        (query,) = fx.queries("api.coinbase.com")
+        self.assertEqual(query["granularity"], "ONE_HOUR")
+        self.assertEqual(int(query["end"]) - int(query["start"]), int(query["end"]) % 3600 + 4 * 3600)
+        self.assertEqual(len(response["write_groups"][0]["data_points"]), 5)
+
+
+if __name__ == "__main__":
+    unittest.main()
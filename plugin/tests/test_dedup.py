@@ -0,0 +1,76 @@
+import threading
+import unittest
+
+from _dedup import dedup_data_points
+from _klines import collect_kline_jobs
+from _timeutil import kline_times
+
+from .fakes import reset_kline_state
+
+
+def _point(object_id: str, times: str, close: float) -> dict:
+    return {"object_id": object_id, "times": times, "fields": {"close": close}}
+
+
+class DedupTest(unittest.TestCase):
+    def test_last_write_wins(self):
+        points = [
+            _point("BTC-USDT", "2024-01-01 00:01:00", 1.0),
+            _point("BTC-USDT", "2024-01-01 00:00:00", 2.0),
+            _point("BTC-USDT", "2024-01-01 00:01:00", 3.0),
+            _point("ETH-USDT", "2024-01-01 00:01:00", 4.0),
+        ]
+        result = dedup_data_points(points)
+        self.assertEqual([(p["object_id"], p["times"], p["fields"]["close"]) for p in result], [
+            ("BTC-USDT", "2024-01-01 00:00:00", 2.0),
+            ("BTC-USDT", "2024-01-01 00:01:00", 3.0),
+            ("ETH-USDT", "2024-01-01 00:01:00", 4.0),
+        ])
+
+    def test_no_duplicates_unchanged(self):
+        points = [_point("BTC-USDT", f"2024-01-01 00:0{i}:00", float(i)) for i in range(3)]
+        self.assertEqual(dedup_data_points(points), points)
+
+
+class OverlappingBatchesTest(unittest.TestCase):
+    """实时任务与补数任务覆盖同一时间窗口：每根 K线只保留一条，取 job 下发顺序中靠后的一条。"""
+
+    def setUp(self):
+        reset_kline_state()
+
+    def _kline(self, open_ms: int, close: float) -> dict:
+        open_time, close_time = kline_times(open_ms, "1m")
+        return {"open_ms": open_ms, "open_time": open_time, "close_time": close_time,
+                "open": 10.0, "high": 20.0, "low": 5.0, "close": close, "volume": 1.0}
+
+    def test_one_record_per_open_time(self):
+        live_done = threading.Event()
+        batches = {
+            "live": [self._kline(t * 60_000, 11.0) for t in range(3, 6)],
+            "backfill": [self._kline(t * 60_000, 12.0) for t in range(0, 5)],
+        }
+
+        def fetch(job, best_ip, deadline):
+            # 先下发的实时任务最后完成，结果仍按下发顺序汇总
+            if job["task_id"] == "live":
+                live_done.wait(0.2)
+            else:
+                live_done.set()
+            return batches[job["task_id"]], None
+
+        jobs = [{"task_id": task_id, "inst_type": "SPOT", "symbol": "BTC-USDT", "interval": "1m",
+                 "domain": "d", "validate": True} for task_id in ("live", "backfill")]
+        result = collect_kline_jobs(
+            jobs, lambda domain: None, "kline", "Test", fetch,
+            lambda symbol, klines: [_point(symbol, k["open_time"], k["close"]) for k in klines], {"SPOT": 1})
+
+        points = result["write_groups"][0]["data_points"]
+        self.assertEqual(len(points), 6)
+        self.assertEqual(len({p["times"] for p in points}), 6)
+        # 00:03、00:04 两个任务重叠，取后下发的补数任务；00:05 只有实时任务
+        closes = [p["fields"]["close"] for p in points]
+        self.assertEqual(closes, [12.0] * 5 + [11.0])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,107 @@
+import json
+import unittest
+from unittest import mock
+
+import _metrics
+
+from .fakes import load_main, make_job, results_by_task, trigger
+
+main = load_main()
+
+
+def _raw_job(task_id: str, task_params) -> dict:
+    return {"task": {"task_id": task_id, "task_params": json.dumps(task_params)}, "interval": "1m"}
+
+
+class UnsupportedCollectorTest(unittest.TestCase):
+    def test_unsupported_data_type_reports_failed(self):
+        response = trigger([make_job("t", "funding_rate", symbol="BTCUSDT")])
+        result = results_by_task(response)["t"]
+        self.assertEqual(result["status"], 4)
+        self.assertIn("'funding_rate'", result["result"])
+        # 上报支持的类型，便于排查配置
+        self.assertIn("'kline'", result["result"])
+        self.assertNotIn("write_groups", response)
+
+    def test_malformed_task_params(self):
+        response = trigger([
+            _raw_job("not_dict", ["kline"]),
+            _raw_job("not_str", {"data_type": ["kline"]}),
+            _raw_job("missing", {"symbol": "BTCUSDT"}),
+            _raw_job("", {"data_type": "funding_rate"}),
+        ])
+        results = results_by_task(response)
+        self.assertEqual(sorted(results), ["missing", "not_dict", "not_str"])
+        self.assertTrue(all(r["status"] == 4 for r in results.values()))
+        self.assertIn("data_type=['kline']", results["not_str"]["result"])
+
+    def test_empty_jobs(self):
+        self.assertEqual(trigger([]), {"status": "ok"})
+
+
+class ParseJobErrorTest(unittest.TestCase):
+    def setUp(self):
+        collector = {"data_type": "test_parse", "parse_job": self._parse_job, "collect": self._collect}
+        for patcher in (mock.patch.dict(main._collector_registry, {"test_parse": collector}),
+                        mock.patch.dict(_metrics._metrics, clear=True)):
+            patcher.start()
+            self.addCleanup(patcher.stop)
+
+    @staticmethod
+    def _parse_job(raw: dict) -> dict:
+        params = json.loads(raw["task"]["task_params"])
+        if params.get("mode") == "invalid":
+            raise ValueError("symbol 不能为空")
+        if params.get("mode") == "bug":
+            return params["missing_key"]
+        if params.get("mode") == "skip":
+            return None
+        return {"task_id": raw["task"]["task_id"]}
+
+    @staticmethod
+    def _collect(jobs: list[dict], get_best_ip) -> dict:
+        return {"task_results": [{"task_id": j["task_id"], "status": 2, "result": ""} for j in jobs],
+                "write_groups": []}
+
+    def test_value_error_vs_other_exceptions(self):
+        response = trigger([
+            make_job("invalid", "test_parse", mode="invalid"),
+            make_job("bug", "test_parse", mode="bug"),
+            make_job("skip", "test_parse", mode="skip"),
+            make_job("ok", "test_parse"),
+        ])
+        results = results_by_task(response)
+        self.assertEqual(results["invalid"], {"task_id": "invalid", "status": 4, "result": "参数错误: symbol 不能为空"})
+        self.assertEqual(results["bug"]["status"], 4)
+        self.assertTrue(results["bug"]["result"].startswith("解析任务失败: KeyError:"))
+        self.assertNotIn("skip", results)
+        self.assertEqual(results["ok"]["status"], 2)
+
+    def test_partial_parse_failure_fails_task(self):
+        response = trigger([
+            make_job("t", "test_parse"),
+            make_job("t", "test_parse", mode="invalid"),
+        ])
+        self.assertEqual(results_by_task(response)["t"]["status"], 4)
+
+
+class MergeTaskResultsTest(unittest.TestCase):
+    def test_merge(self):
+        merged = main._merge_task_results([
+            {"task_id": "a", "status": 2, "result": ""},
+            {"task_id": "b", "status": 4, "result": "e1"},
+            {"task_id": "a", "status": 4, "result": "e2"},
+            {"task_id": "b", "status": 2, "result": ""},
+            {"task_id": "b", "status": 4, "result": "e3"},
+            {"task_id": "c", "status": 2, "result": ""},
+            {"task_id": "c", "status": 2, "result": ""},
+        ])
+        self.assertEqual(merged, [
+            {"task_id": "a", "status": 4, "result": "e2"},
+            {"task_id": "b", "status": 4, "result": "e1; e3"},
+            {"task_id": "c", "status": 2, "result": ""},
+        ])
+
+
+if __name__ == "__main__":
+    unittest.main()
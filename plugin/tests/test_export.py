@@ -0,0 +1,51 @@
+import json
+import unittest
+from unittest import mock
+
+import _metrics
+import _ratelimit
+
+from .fakes import load_main
+
+main = load_main()
+
+
+class ExportCollectorsTest(unittest.TestCase):
+    def setUp(self):
+        for patcher in (mock.patch.dict(_metrics._metrics, clear=True),
+                        mock.patch.dict(_ratelimit._limiters, clear=True)):
+            patcher.start()
+            self.addCleanup(patcher.stop)
+
+    def test_all_registered_collectors(self):
+        exported = main.export_collectors()
+        self.assertEqual(exported["count"], len(main._collector_registry))
+        self.assertEqual([c["data_type"] for c in exported["collectors"]], sorted(main._collector_registry))
+
+    def test_json_serializable_without_callables(self):
+        exported = main.export_collectors()
+        # 与 /collectors 响应一致，可直接 JSON 序列化
+        self.assertEqual(json.loads(json.dumps(exported)), exported)
+        for descriptor in exported["collectors"]:
+            with self.subTest(data_type=descriptor["data_type"]):
+                self.assertNotIn("collect", descriptor)
+                self.assertNotIn("parse_job", descriptor)
+                self.assertTrue(descriptor["has_parse_job"])
+                self.assertTrue(descriptor["module"].startswith("exchange_"))
+
+    def test_metrics_and_rate_limits_before_first_run(self):
+        by_type = {c["data_type"]: c for c in main.export_collectors()["collectors"]}
+        kline = by_type["kline"]
+        self.assertIsNone(kline["metrics"])
+        self.assertEqual(sorted(kline["rate_limits"]), sorted(main._collector_registry["kline"]["rate_limit_keys"]))
+        self.assertTrue(all(v is None for v in kline["rate_limits"].values()))
+
+    def test_rate_limits_after_request(self):
+        key = main._collector_registry["kline"]["rate_limit_keys"][0]
+        _ratelimit.get_limiter(key, 1200, 20).acquire()
+        rate_limits = {c["data_type"]: c for c in main.export_collectors()["collectors"]}["kline"]["rate_limits"]
+        self.assertEqual(rate_limits[key]["acquired_total"], 1)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,82 @@
+import unittest
+
+from _intervals import CANONICAL_INTERVALS, parse_interval, to_freq
+
+from .fakes import FakeExchange, make_job, reset_kline_state, results_by_task, trigger
+
+
+class ParseIntervalTest(unittest.TestCase):
+    def test_canonical_intervals(self):
+        for interval in CANONICAL_INTERVALS:
+            with self.subTest(interval=interval):
+                self.assertEqual(parse_interval(interval), interval)
+
+    def test_aliases(self):
+        cases = [
+            ("1H", "", "1h"),
+            ("4H", "", "4h"),
+            ("1D", "", "1d"),
+            ("1W", "", "1w"),
+            (" 15m ", "", "15m"),
+            ("1Dutc", "okx", "1d"),
+            ("1Mutc", "okx", "1M"),
+            ("ONE_MINUTE", "coinbase", "1m"),
+            ("SIX_HOUR", "coinbase", "6h"),
+            ("60", "kraken", "1h"),
+            ("10080", "kraken", "1w"),
+        ]
+        for raw, exchange, expected in cases:
+            with self.subTest(raw=raw, exchange=exchange):
+                self.assertEqual(parse_interval(raw, exchange), expected)
+
+    def test_rejects_garbage(self):
+        cases = [
+            ("", ""),
+            ("1x", ""),
+            ("7m", ""),
+            ("1M ago", ""),
+            ("5H", ""),
+            (None, ""),
+            (60, "kraken"),
+            # 别名只在所属交易所生效
+            ("60", ""),
+            ("ONE_HOUR", "okx"),
+            ("1Dutc", "binance"),
+        ]
+        for raw, exchange in cases:
+            with self.subTest(raw=raw, exchange=exchange):
+                with self.assertRaises(ValueError):
+                    parse_interval(raw, exchange)
+
+    def test_supported_restriction(self):
+        self.assertEqual(parse_interval("ONE_HOUR", "coinbase", {"1h": None}), "1h")
+        with self.assertRaises(ValueError) as ctx:
+            parse_interval("3m", "coinbase", {"1m": None, "5m": None})
+        self.assertIn("3m", str(ctx.exception))
+
+    def test_to_freq(self):
+        self.assertEqual([to_freq(i) for i in ("1m", "1h", "12h", "1d", "1w", "1M")],
+                         ["1m", "1H", "12H", "1D", "1w", "1M"])
+
+
+class InvalidIntervalTaskTest(unittest.TestCase):
+    def setUp(self):
+        reset_kline_state()
+
+    def test_unknown_interval_reports_failed(self):
+        with FakeExchange() as fx:
+            response = trigger([
+                make_job("bad", "kline", "7m", inst_type="SPOT", symbol="BTCUSDT"),
+                make_job("unsupported", "coinbase_kline", "3m", symbol="BTC-USD"),
+                make_job("ok", "kline", "1H", inst_type="SPOT", symbol="BTCUSDT"),
+            ])
+        results = results_by_task(response)
+        self.assertEqual(results["bad"]["status"], 4)
+        self.assertIn("7m", results["bad"]["result"])
+        self.assertEqual(results["unsupported"]["status"], 4)
+        self.assertEqual(results["ok"]["status"], 2)
+        self.assertEqual([q["interval"] for _, _, q in fx.calls], ["1h"])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,129 @@
+import unittest
+from unittest import mock
+
+import _metrics
+import exchange_binance_kline
+from _klines import collect_kline_jobs, validate_kline, validate_klines
+from _timeutil import kline_times
+
+from .fakes import FakeExchange, make_job, reset_kline_state, results_by_task, trigger
+
+
+class CollectFlowTest(unittest.TestCase):
+    """各 K线插件共用的 collect 流程（_klines.collect_kline_jobs）。"""
+
+    def setUp(self):
+        reset_kline_state()
+
+    def test_groups_by_interval(self):
+        with FakeExchange():
+            response = trigger([
+                make_job("m1", "coinbase_kline", "1m", symbol="BTC-USD"),
+                make_job("h1", "coinbase_kline", "1h", symbol="BTC-USD"),
+                make_job("eth", "coinbase_kline", "1m", symbol="ETH-USD"),
+            ])
+        groups = {g["freq"]: g for g in response["write_groups"]}
+        self.assertEqual(sorted(groups), ["1H", "1m"])
+        self.assertEqual(sorted({p["object_id"] for p in groups["1m"]["data_points"]}), ["BTC-USD", "ETH-USD"])
+        self.assertEqual({p["object_id"] for p in groups["1H"]["data_points"]}, {"BTC-USD"})
+
+    def test_failure_isolated_to_task(self):
+        with FakeExchange() as fx:
+            fx.fail = lambda domain, path, query: "ETH-USD" in path
+            response = trigger([
+                make_job("btc", "coinbase_kline", "1m", symbol="BTC-USD"),
+                make_job("eth", "coinbase_kline", "1m", symbol="ETH-USD"),
+            ])
+        results = results_by_task(response)
+        self.assertEqual(results["btc"]["status"], 2)
+        self.assertEqual(results["eth"]["status"], 4)
+        self.assertIn("ETH-USD", results["eth"]["result"])
+        points = response["write_groups"][0]["data_points"]
+        self.assertEqual({p["object_id"] for p in points}, {"BTC-USD"})
+
+    def test_any_job_failure_fails_task(self):
+        with FakeExchange() as fx:
+            fx.fail = lambda domain, path, query: "ETH-USD" in path
+            job = make_job("t", "coinbase_kline", "1m", symbol="BTC-USD")
+            other = make_job("t", "coinbase_kline", "1m", symbol="ETH-USD")
+            response = trigger([job, other])
+        self.assertEqual(results_by_task(response)["t"]["status"], 4)
+        # 成功的 job 已获取的数据照常写入
+        self.assertEqual(len(response["write_groups"][0]["data_points"]), 5)
+
+
+def _kline(open_ms: int, interval: str = "1m", **fields) -> dict:
+    open_time, close_time = kline_times(open_ms, interval)
+    kline = {"open_ms": open_ms, "open_time": open_time, "close_time": close_time,
+             "open": 10.0, "high": 12.0, "low": 9.0, "close": 11.0, "volume": 1.0}
+    kline.update(fields)
+    return kline
+
+
+class ValidateKlineTest(unittest.TestCase):
+    def test_valid(self):
+        self.assertIsNone(validate_kline(_kline(0)))
+        self.assertIsNone(validate_kline(_kline(0, open=11.0, close=10.0)))
+        self.assertIsNone(validate_kline(_kline(0, open=9.0, high=9.0, low=9.0, close=9.0, volume=0.0)))
+
+    def test_invariants(self):
+        cases = [
+            ("负价格", {"low": -1.0}),
+            ("high 低于 close", {"high": 10.5}),
+            ("high 低于 open", {"open": 12.5, "close": 10.0}),
+            ("low 高于 open", {"low": 10.5}),
+            ("low 高于 close", {"open": 11.0, "close": 9.5, "low": 9.8}),
+            ("负成交量", {"volume": -1.0}),
+            ("负成交额", {"quote_volume": -0.5}),
+            ("close_time 早于 open_time", {"close_time": "1969-12-31 23:59:59"}),
+        ]
+        for name, fields in cases:
+            with self.subTest(name):
+                self.assertIsNotNone(validate_kline(_kline(0, **fields)))
+
+    def test_drops_unaligned_and_non_increasing(self):
+        klines = [_kline(0), _kline(60_000), _kline(90_000), _kline(60_000), _kline(120_000, low=-1.0),
+                  _kline(180_000)]
+        valid = validate_klines("BTC-USDT", "1m", klines)
+        self.assertEqual([k["open_ms"] for k in valid], [0, 60_000, 180_000])
+
+
+class ValidationMetricTest(unittest.TestCase):
+    def setUp(self):
+        patcher = mock.patch.dict(_metrics._metrics, clear=True)
+        patcher.start()
+        self.addCleanup(patcher.stop)
+
+    def _collect(self, validate: bool) -> dict:
+        klines = [_kline(0), _kline(60_000, high=1.0), _kline(120_000, volume=-1.0), _kline(180_000)]
+        job = {"task_id": "t", "inst_type": "SPOT", "symbol": "BTC-USDT", "interval": "1m",
+               "domain": "d", "validate": validate}
+        return collect_kline_jobs(
+            [job], lambda domain: None, "test_kline", "Test", lambda job, best_ip, deadline: (list(klines), None),
+            lambda symbol, ks: [{"object_id": symbol, "times": k["open_time"], "fields": {}} for k in ks],
+            {"SPOT": 1})
+
+    def test_dropped_counted(self):
+        result = self._collect(validate=True)
+        self.assertEqual(len(result["write_groups"][0]["data_points"]), 2)
+        self.assertEqual(result["task_results"][0]["status"], 2)
+        self.assertEqual(_metrics.collector_metrics("test_kline")["kline_validation_failed_total"], 2)
+
+        self._collect(validate=True)
+        self.assertEqual(_metrics.metrics_summary()["kline_validation_failed_total"], 4)
+
+    def test_validation_disabled(self):
+        result = self._collect(validate=False)
+        self.assertEqual(len(result["write_groups"][0]["data_points"]), 4)
+        self.assertIsNone(_metrics.collector_metrics("test_kline"))
+
+    def test_validate_kline_param(self):
+        job = exchange_binance_kline.parse_job(
+            make_job("t", "kline", "1m", inst_type="SPOT", symbol="BTCUSDT", validate_kline=False))
+        self.assertFalse(job["validate"])
+        job = exchange_binance_kline.parse_job(make_job("t", "kline", "1m", inst_type="SPOT", symbol="BTCUSDT"))
+        self.assertTrue(job["validate"])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,98 @@
+import unittest
+
+import _kraken
+import exchange_kraken_kline
+import exchange_kraken_symbol
+
+from .fakes import FakeExchange, make_job, reset_kline_state, results_by_task, trigger
+
+_HOUR_MS = 3_600_000
+
+# 录制的 AssetPairs 响应（节选）
+_ASSET_PAIRS = {
+    "XXBTZUSD": {"altname": "XBTUSD", "wsname": "XBT/USD", "base": "XXBT", "quote": "ZUSD", "status": "online"},
+    "XETHZUSD": {"altname": "ETHUSD", "wsname": "XETH/USD", "base": "XETH", "quote": "ZUSD", "status": "online"},
+    "XDGUSD": {"altname": "XDGUSD", "wsname": "XDG/USD", "base": "XXDG", "quote": "ZUSD", "status": "online"},
+    "XXBTZEUR": {"altname": "XBTEUR", "wsname": "XBT/EUR", "base": "XXBT", "quote": "ZEUR", "status": "online"},
+    "XXBTZUSD.d": {"altname": "XBTUSD.d", "base": "XXBT", "quote": "ZUSD", "status": "online"},
+    "SOLUSD": {"altname": "SOLUSD", "wsname": "SOL/USD", "base": "SOL", "quote": "ZUSD", "status": "cancel_only"},
+}
+
+
+class KrakenMappingTest(unittest.TestCase):
+    def test_split_symbol(self):
+        cases = [
+            ("XXBTZUSD", ("BTC", "USD")),
+            ("XETHZUSD", ("ETH", "USD")),
+            ("XBTUSD", ("BTC", "USD")),
+            ("XBT/USD", ("BTC", "USD")),
+            ("BTC-USD", ("BTC", "USD")),
+            ("XDGUSD", ("DOGE", "USD")),
+            ("ETHUSDT", ("ETH", "USDT")),
+        ]
+        for raw, expected in cases:
+            with self.subTest(raw=raw):
+                self.assertEqual(_kraken.split_symbol(raw), expected)
+
+    def test_to_pair(self):
+        self.assertEqual(_kraken.to_pair("BTC-USD"), "XBTUSD")
+        self.assertEqual(_kraken.to_pair("DOGE-USD"), "XDGUSD")
+        self.assertEqual(_kraken.to_pair("ETH-USDT"), "ETHUSDT")
+
+    def test_asset_pairs_fixture(self):
+        symbols = exchange_kraken_symbol._filter_pairs(_ASSET_PAIRS)
+        self.assertEqual([s["symbol"] for s in symbols], ["BTC-USD", "DOGE-USD", "ETH-USD"])
+
+    def test_ohlc_row_fixture(self):
+        row = [1704067200, "42283.6", "42554.1", "42261.0", "42475.2", "42400.3", "91.52", 1234]
+        kline = exchange_kraken_kline._parse_row(row, "1h")
+        self.assertEqual(kline["open_time"], "2024-01-01 00:00:00")
+        self.assertEqual(kline["close_time"], "2024-01-01 00:59:59")
+        self.assertEqual((kline["open"], kline["high"], kline["low"], kline["close"]),
+                         (42283.6, 42554.1, 42261.0, 42475.2))
+        self.assertEqual((kline["volume"], kline["trade_count"]), (91.52, 1234))
+
+    def test_interval_minutes(self):
+        job = exchange_kraken_kline.parse_job(make_job("t", "kraken_kline", "60", symbol="XBTUSD"))
+        self.assertEqual((job["interval"], job["pair"], job["symbol"]), ("1h", "XBTUSD", "BTC-USD"))
+
+
+class KrakenCursorTest(unittest.TestCase):
+    def setUp(self):
+        reset_kline_state()
+
+    def test_incremental_polling_uses_last(self):
+        job = make_job("t", "kraken_kline", "1h", symbol="BTC-USD")
+        with FakeExchange() as fx:
+            first = trigger([job])
+            first_call = fx.calls[-1]
+            cursor = exchange_kraken_kline._cursors[("XBTUSD", "1h")]
+
+            # 三个周期后再次触发：从上一轮的 last 开始，补齐期间的 K线
+            fx.now_ms += 3 * _HOUR_MS
+            second = trigger([job])
+
+        since = [int(q["since"]) for _, _, q in fx.calls]
+        self.assertEqual(since[1], cursor)
+        self.assertEqual(cursor, (fx.now_ms - 3 * _HOUR_MS) // _HOUR_MS * 3600 - 3600)
+        self.assertEqual(first_call[2]["pair"], "XBTUSD")
+
+        first_times = [p["times"] for p in first["write_groups"][0]["data_points"]]
+        second_times = [p["times"] for p in second["write_groups"][0]["data_points"]]
+        self.assertEqual(len(first_times), 6)
+        # 上一轮最后一根已收盘 K线、未收盘的一根，以及新增的三根
+        self.assertEqual(second_times[:2], first_times[-2:])
+        self.assertEqual(len(second_times), 5)
+        self.assertEqual(exchange_kraken_kline._cursors[("XBTUSD", "1h")], cursor + 3 * 3600)
+
+    def test_kraken_error_fails_task(self):
+        with FakeExchange() as fx:
+            fx.static[("api.kraken.com", "/0/public/OHLC")] = {"error": ["EQuery:Unknown asset pair"]}
+            response = trigger([make_job("t", "kraken_kline", "1h", symbol="FOO-USD")])
+        result = results_by_task(response)["t"]
+        self.assertEqual(result["status"], 4)
+        self.assertIn("EQuery:Unknown asset pair", result["result"])
+
+
+if __name__ == "__main__":
+    unittest.main()
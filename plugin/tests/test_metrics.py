@@ -0,0 +1,100 @@
+import unittest
+from unittest import mock
+
+import _metrics
+
+from .fakes import FakeClock, FakeExchange, load_main, make_job, reset_kline_state, trigger
+
+main = load_main()
+
+
+def _result(statuses: list[int], data_points: int) -> dict:
+    return {
+        "task_results": [{"task_id": str(i), "status": s, "result": ""} for i, s in enumerate(statuses)],
+        "write_groups": [{"data_points": [{}] * data_points}] if data_points else [],
+    }
+
+
+class RecordCollectTest(unittest.TestCase):
+    def setUp(self):
+        self.clock = FakeClock()
+        for patcher in (mock.patch.dict(_metrics._metrics, clear=True),
+                        mock.patch.object(_metrics, "time", self.clock),
+                        mock.patch.object(_metrics, "_started_at", self.clock.time() - 120)):
+            patcher.start()
+            self.addCleanup(patcher.stop)
+
+    def test_per_collector(self):
+        self.assertIsNone(_metrics.collector_metrics("kline"))
+        _metrics.record_collect("kline", _result([2, 2, 4], 30), 1.5)
+        self.clock.sleep(60)
+        _metrics.record_collect("kline", _result([2], 0), 0.25)
+
+        m = _metrics.collector_metrics("kline")
+        self.assertEqual((m["runs_total"], m["tasks_succeeded_total"], m["tasks_failed_total"]), (2, 3, 1))
+        self.assertEqual(m["task_error_rate"], 0.25)
+        self.assertEqual((m["data_points_total"], m["duration_seconds_total"]), (30, 1.75))
+        self.assertEqual(m["last_duration_seconds"], 0.25)
+        # 最后一次执行没有产出数据，last_data_at 仍为上一次
+        self.assertEqual(m["last_run_at"] - m["last_data_at"], 60)
+        self.assertEqual((m["run_errors_total"], m["last_error"]), (0, None))
+
+    def test_run_error(self):
+        _metrics.record_collect("symbol", {}, 0.1, error="boom")
+        m = _metrics.collector_metrics("symbol")
+        self.assertEqual((m["runs_total"], m["run_errors_total"], m["last_error"]), (1, 1, "boom"))
+        self.assertIsNone(m["last_data_at"])
+
+    def test_summary(self):
+        _metrics.record_collect("kline", _result([2, 4], 60), 1.0)
+        _metrics.record_collect("symbol", _result([2, 2], 180), 1.0)
+        _metrics.record_kline_validation_failed("kline", 3)
+        summary = _metrics.metrics_summary()
+        self.assertEqual(summary, {
+            "uptime_seconds": 120.0,
+            "runs_total": 2,
+            "tasks_total": 4,
+            "tasks_failed_total": 1,
+            "task_error_rate": 0.25,
+            "data_points_total": 240,
+            "kline_validation_failed_total": 3,
+            "data_points_per_minute": 120.0,
+        })
+
+    def test_empty_summary(self):
+        summary = _metrics.metrics_summary()
+        self.assertEqual((summary["runs_total"], summary["task_error_rate"]), (0, 0.0))
+
+
+class DispatchMetricsTest(unittest.TestCase):
+    def setUp(self):
+        reset_kline_state()
+        patcher = mock.patch.dict(_metrics._metrics, clear=True)
+        patcher.start()
+        self.addCleanup(patcher.stop)
+
+    def test_recorded_per_data_type(self):
+        with FakeExchange() as fx:
+            fx.fail = lambda domain, path, query: "ETH-USD" in path
+            trigger([
+                make_job("btc", "coinbase_kline", "1m", symbol="BTC-USD"),
+                make_job("eth", "coinbase_kline", "1m", symbol="ETH-USD"),
+            ])
+        m = _metrics.collector_metrics("coinbase_kline")
+        self.assertEqual((m["runs_total"], m["tasks_succeeded_total"], m["tasks_failed_total"]), (1, 1, 1))
+        self.assertEqual(m["data_points_total"], 5)
+        self.assertIsNone(_metrics.collector_metrics("kline"))
+
+    def test_collect_exception_recorded(self):
+        def collect(jobs, get_best_ip):
+            raise RuntimeError("boom")
+
+        with mock.patch.dict(main._collector_registry, {"test_raise": {"data_type": "test_raise", "collect": collect}}):
+            with self.assertRaises(RuntimeError):
+                trigger([make_job("t", "test_raise")])
+        m = _metrics.collector_metrics("test_raise")
+        self.assertEqual((m["runs_total"], m["run_errors_total"], m["last_error"]), (1, 1, "boom"))
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,103 @@
+import unittest
+
+import exchange_okx_kline as okx
+from _timeutil import parse_time_ms
+
+from .fakes import FakeExchange, make_job, reset_kline_state, results_by_task, trigger
+
+
+class InstIdMappingTest(unittest.TestCase):
+    def test_to_inst_id(self):
+        cases = [
+            ("BTC-USDT", "SPOT", "", "BTC-USDT"),
+            ("btcusdt", "SPOT", "", "BTC-USDT"),
+            ("BTC/USDT", "SWAP", "", "BTC-USDT-SWAP"),
+            ("BTC-USD", "FUTURES", "250328", "BTC-USD-250328"),
+            ("btc-usd-250627", "FUTURES", "", "BTC-USD-250627"),
+        ]
+        for symbol, inst_type, expiry, expected in cases:
+            with self.subTest(symbol=symbol, inst_type=inst_type):
+                self.assertEqual(okx.to_inst_id(symbol, inst_type, expiry), expected)
+
+    def test_to_inst_id_rejects(self):
+        with self.assertRaises(ValueError):
+            okx.to_inst_id("BTC-USD", "FUTURES")
+        with self.assertRaises(ValueError):
+            okx.to_inst_id("BTC-USDT", "OPTION")
+
+    def test_from_inst_id(self):
+        for inst_id in ("BTC-USDT", "BTC-USDT-SWAP", "BTC-USDT-250328"):
+            with self.subTest(inst_id=inst_id):
+                self.assertEqual(okx.from_inst_id(inst_id), "BTC-USDT")
+
+    def test_object_id_keeps_expiry(self):
+        self.assertEqual(okx.to_object_id("BTC-USDT-SWAP"), "BTC-USDT")
+        self.assertEqual(okx.to_object_id("BTC-USD-250328"), "BTC-USD-250328")
+
+    def test_bar_mapping(self):
+        cases = [("1m", "1m"), ("1h", "1H"), ("4h", "4H"), ("6h", "6Hutc"), ("1d", "1Dutc"), ("1w", "1Wutc"),
+                 ("1M", "1Mutc")]
+        for interval, bar in cases:
+            with self.subTest(interval=interval):
+                self.assertEqual(okx.to_bar(interval), bar)
+                self.assertEqual(okx.from_bar(bar), interval)
+        self.assertEqual(okx.from_bar("1D"), "1d")
+        with self.assertRaises(ValueError):
+            okx.to_bar("3d")
+
+
+class OkxRowTest(unittest.TestCase):
+    # 录制的 candles 行: [ts, o, h, l, c, vol, volCcy, volCcyQuote, confirm]
+    _ROW = ["1704067200000", "42283.6", "42554.1", "42261.0", "42475.2", "1520", "15.2", "645000.5", "1"]
+
+    def test_spot_volume(self):
+        kline = okx._parse_row(self._ROW, "1h")
+        self.assertEqual((kline["open_time"], kline["close_time"]), ("2024-01-01 00:00:00", "2024-01-01 00:59:59"))
+        self.assertEqual((kline["volume"], kline["quote_volume"], kline["contract_volume"]), (1520.0, 645000.5, None))
+
+    def test_derivative_volume_in_base_asset(self):
+        kline = okx._parse_row(self._ROW, "1h", derivative=True)
+        self.assertEqual((kline["volume"], kline["contract_volume"]), (15.2, 1520.0))
+        fields = okx._format_data_points("BTC-USDT", [kline])[0]["fields"]
+        self.assertEqual(fields["contract_volume"], 1520.0)
+
+
+class OkxCollectTest(unittest.TestCase):
+    def setUp(self):
+        reset_kline_state()
+
+    def test_futures_expiries_stay_separate(self):
+        with FakeExchange():
+            response = trigger([
+                make_job("mar", "okx_kline", "1m", inst_type="FUTURES", symbol="BTC-USD", expiry="250328"),
+                make_job("jun", "okx_kline", "1m", inst_type="FUTURES", symbol="BTC-USD", expiry="250627"),
+            ])
+        group = response["write_groups"][0]
+        self.assertEqual(group["dataset_id"], 202)
+        self.assertEqual(sorted({p["object_id"] for p in group["data_points"]}), ["BTC-USD-250328", "BTC-USD-250627"])
+        self.assertEqual(len(group["data_points"]), 10)
+
+    def test_history_paging_cursors(self):
+        start = parse_time_ms("2024-01-01 00:00:00")
+        end = parse_time_ms("2024-01-11 10:00:00")
+        with FakeExchange() as fx:
+            response = trigger([make_job("t", "okx_kline", "1H", inst_type="SWAP", symbol="BTC-USDT",
+                                         start_time="2024-01-01 00:00:00", end_time="2024-01-11 10:00:00")])
+        self.assertEqual(results_by_task(response)["t"]["status"], 2)
+
+        pages = fx.queries("www.okx.com")
+        self.assertEqual([q["instId"] for q in pages], ["BTC-USDT-SWAP"] * 3)
+        # 每页 100 个周期的时间窗口：before=窗口起点-1，after=窗口终点，窗口首尾相接
+        hour = 3_600_000
+        self.assertEqual([(int(q["before"]), int(q["after"])) for q in pages], [
+            (start - 1, start + 100 * hour),
+            (start + 100 * hour - 1, start + 200 * hour),
+            (start + 200 * hour - 1, end),
+        ])
+        points = response["write_groups"][0]["data_points"]
+        self.assertEqual(len(points), 250)
+        self.assertEqual((points[0]["times"], points[-1]["times"]), ("2024-01-01 00:00:00", "2024-01-11 09:00:00"))
+
+
+if __name__ == "__main__":
+    unittest.main()
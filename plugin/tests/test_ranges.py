@@ -0,0 +1,135 @@
+import time
+import unittest
+from types import SimpleNamespace
+from unittest import mock
+
+import _klines
+import exchange_binance_kline
+import exchange_coinbase_kline
+import exchange_okx_kline
+from _timeutil import format_ms, parse_time_ms
+
+from .fakes import FakeExchange, make_job, reset_kline_state, results_by_task, trigger
+
+# (data_type, 插件模块, 额外参数, 域名, 单页根数)
+_EXCHANGES = [
+    ("kline", exchange_binance_kline, {"inst_type": "SPOT", "symbol": "BTCUSDT"}, "api.binance.com", 1000),
+    ("okx_kline", exchange_okx_kline, {"inst_type": "SWAP", "symbol": "BTC-USDT"}, "www.okx.com", 100),
+    ("coinbase_kline", exchange_coinbase_kline, {"symbol": "BTC-USD"}, "api.coinbase.com", 300),
+]
+
+_START = "2024-01-01 00:00:00"
+_HOUR_MS = 3_600_000
+
+
+def _points(response: dict) -> list[dict]:
+    return [p for g in response.get("write_groups", []) for p in g["data_points"]]
+
+
+class FixedRangeTest(unittest.TestCase):
+    """指定 start_time / end_time 的区间任务：跨页拉取完整、可续传、完成后不再重复请求。"""
+
+    def setUp(self):
+        reset_kline_state()
+
+    def _job(self, data_type: str, params: dict, bars: int):
+        end_time = format_ms(parse_time_ms(_START) + bars * _HOUR_MS)
+        return make_job("t", data_type, "1h", start_time=_START, end_time=end_time, **params)
+
+    def test_multi_page_complete(self):
+        for data_type, _, params, domain, page in _EXCHANGES:
+            with self.subTest(data_type=data_type):
+                reset_kline_state()
+                bars = page * 2 + page // 2
+                job = self._job(data_type, params, bars)
+                with FakeExchange() as fx:
+                    response = trigger([job])
+                    self.assertEqual(len(fx.queries(domain)), 3)
+
+                    # 区间已完成：再次触发不发起请求，也不重复写入
+                    again = trigger([job])
+                    self.assertEqual(len(fx.queries(domain)), 3)
+
+                self.assertEqual(results_by_task(response)["t"]["status"], 2)
+                times = [p["times"] for p in _points(response)]
+                self.assertEqual(len(times), bars)
+                self.assertEqual(len(set(times)), bars)
+                self.assertEqual(times[0], _START)
+                self.assertEqual(results_by_task(again)["t"]["status"], 2)
+                self.assertEqual(_points(again), [])
+
+    def test_inverted_range_rejected(self):
+        for data_type, module, params, _, _ in _EXCHANGES:
+            with self.subTest(data_type=data_type):
+                job = make_job("t", data_type, "1h", start_time=_START, end_time="2023-12-31 00:00:00", **params)
+                with self.assertRaises(ValueError):
+                    module.parse_job(job)
+                with FakeExchange() as fx:
+                    result = results_by_task(trigger([job]))["t"]
+                self.assertEqual(result["status"], 4)
+                self.assertTrue(result["result"].startswith("参数错误: 时间范围无效"))
+                self.assertEqual(fx.calls, [])
+
+    def test_page_limit_resumes_next_trigger(self):
+        for data_type, module, params, domain, page in _EXCHANGES:
+            with self.subTest(data_type=data_type):
+                reset_kline_state()
+                job = self._job(data_type, params, page * 2 + 10)
+                times = []
+                with mock.patch.object(module, "_MAX_PAGES", 1), FakeExchange() as fx:
+                    statuses = []
+                    for _ in range(3):
+                        response = trigger([job])
+                        statuses.append(results_by_task(response)["t"]["status"])
+                        times.extend(p["times"] for p in _points(response))
+                    # 第一、二次触发各拉取一页后上报 FAILED（已获取部分照常写入），第三次完成
+                    self.assertEqual(statuses, [4, 4, 2])
+                    self.assertEqual(len(fx.queries(domain)), 3)
+                self.assertEqual(len(times), page * 2 + 10)
+                self.assertEqual(len(set(times)), len(times))
+
+    def test_zero_budget_makes_no_request(self):
+        job = self._job("kline", _EXCHANGES[0][2], 10)
+        with mock.patch.object(_klines, "RANGE_TIME_BUDGET_SECONDS", 0), FakeExchange() as fx:
+            result = results_by_task(trigger([job]))["t"]
+        self.assertEqual(result["status"], 4)
+        self.assertIn("单次触发限时 0s", result["result"])
+        self.assertEqual(fx.calls, [])
+
+    def test_budget_shared_across_data_types(self):
+        # 截止时间在触发开始时确定：第一个 data_type 耗尽预算后，其余 data_type 的区间任务不再发起请求
+        jobs = [self._job("kline", _EXCHANGES[0][2], 10), self._job("okx_kline", _EXCHANGES[1][2], 10)]
+        jobs[1]["task"]["task_id"] = "okx"
+        # 每次读取 monotonic 时钟前进 15 秒：开始触发为 0（截止 20），Binance 翻页前为 15，OKX 翻页前为 30
+        clock = iter(range(0, 1000, 15))
+        fake_time = SimpleNamespace(monotonic=lambda: next(clock), time=time.time)
+        with mock.patch.object(_klines, "time", fake_time), FakeExchange() as fx:
+            results = results_by_task(trigger(jobs))
+        self.assertEqual((results["t"]["status"], results["okx"]["status"]), (2, 4))
+        self.assertEqual(fx.queries("www.okx.com"), [])
+
+    def test_failure_keeps_partial_and_resumes(self):
+        for data_type, _, params, domain, page in _EXCHANGES:
+            with self.subTest(data_type=data_type):
+                reset_kline_state()
+                job = self._job(data_type, params, page * 2 + 10)
+                with FakeExchange() as fx:
+                    fx.fail = lambda d, path, query: len(fx.queries(domain)) == 2
+                    first = trigger([job])
+                    fx.fail = None
+                    second = trigger([job])
+                result = results_by_task(first)["t"]
+                self.assertEqual(result["status"], 4)
+                self.assertIn("请求失败", result["result"])
+                self.assertEqual(results_by_task(second)["t"]["status"], 2)
+
+                first_times = [p["times"] for p in _points(first)]
+                second_times = [p["times"] for p in _points(second)]
+                self.assertEqual(len(first_times), page)
+                self.assertEqual(first_times[0], _START)
+                self.assertEqual(len(set(first_times + second_times)), page * 2 + 10)
+                self.assertEqual(len(first_times) + len(second_times), page * 2 + 10)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,123 @@
+import unittest
+from unittest import mock
+
+import _ratelimit
+import exchange_binance_kline
+import exchange_binance_symbol
+from _ratelimit import RateLimitedError, TokenBucket, get_limiter
+
+from .fakes import FakeClock, FakeExchange, make_job, reset_kline_state, results_by_task, trigger
+
+
+class TokenBucketTest(unittest.TestCase):
+    def setUp(self):
+        self.clock = FakeClock()
+        patcher = mock.patch.object(_ratelimit, "time", self.clock)
+        patcher.start()
+        self.addCleanup(patcher.stop)
+
+    def test_starts_full_and_refills_at_rate(self):
+        bucket = TokenBucket("t", requests_per_minute=60, burst=5)
+        for _ in range(5):
+            self.assertTrue(bucket.try_acquire())
+        self.assertFalse(bucket.try_acquire())
+
+        self.clock.now += 2.5
+        self.assertAlmostEqual(bucket.remaining(), 2.5)
+
+    def test_refill_capped_at_burst(self):
+        bucket = TokenBucket("t", requests_per_minute=60, burst=5)
+        bucket.acquire(5)
+        self.clock.now += 3600
+        self.assertEqual(bucket.remaining(), 5)
+
+    def test_sustained_rate_capped(self):
+        # 600 次/分钟（10 次/秒）、burst 10：110 次请求中超出 burst 的 100 次至少需要 10 秒
+        bucket = TokenBucket("t", requests_per_minute=600, burst=10)
+        started = self.clock.now
+        for _ in range(110):
+            bucket.acquire(timeout=None)
+        self.assertAlmostEqual(self.clock.now - started, 10.0, places=3)
+        self.assertEqual(bucket.stats()["acquired_total"], 110)
+
+    def test_weighted_acquire(self):
+        bucket = TokenBucket("t", requests_per_minute=60, burst=10)
+        bucket.acquire(4)
+        self.assertAlmostEqual(bucket.remaining(), 6)
+        with self.assertRaises(ValueError):
+            bucket.acquire(11)
+
+    def test_rate_limited_error_when_wait_exceeds_timeout(self):
+        bucket = TokenBucket("t", requests_per_minute=60, burst=1)
+        bucket.acquire()
+        with self.assertRaises(RateLimitedError) as ctx:
+            bucket.acquire(timeout=0.5)
+        self.assertAlmostEqual(ctx.exception.wait_seconds, 1.0)
+        self.assertEqual(self.clock.slept, [])
+        self.assertEqual(bucket.stats()["rejected_total"], 1)
+
+        bucket.acquire(timeout=2)
+        self.assertEqual(self.clock.slept, [1.0])
+
+    def test_invalid_config(self):
+        with self.assertRaises(ValueError):
+            TokenBucket("t", requests_per_minute=0, burst=1)
+        with self.assertRaises(ValueError):
+            TokenBucket("t", requests_per_minute=60, burst=0)
+
+    def test_stats(self):
+        bucket = TokenBucket("t", requests_per_minute=120, burst=4)
+        bucket.acquire()
+        stats = bucket.stats()
+        self.assertEqual(stats["remaining"], 3)
+        self.assertEqual(stats["requests_per_minute"], 120)
+        self.assertEqual(stats["last_acquired_at"], self.clock.now)
+
+
+class SharedLimiterTest(unittest.TestCase):
+    """同一交易所的多个插件共用按域名注册的限流器，实际请求前按权重消耗令牌。"""
+
+    def setUp(self):
+        reset_kline_state()
+        self.clock = FakeClock()
+        for patcher in (mock.patch.object(_ratelimit, "time", self.clock),
+                        mock.patch.dict(_ratelimit._limiters, clear=True),
+                        mock.patch.object(exchange_binance_symbol, "_EXCHANGE_INFO_TTL_SECONDS", 0)):
+            patcher.start()
+            self.addCleanup(patcher.stop)
+
+    def test_get_limiter_shared(self):
+        limiter = get_limiter("api.example.com", 60, 5)
+        self.assertIs(get_limiter("api.example.com", 120, 10), limiter)
+        self.assertEqual(limiter.requests_per_minute, 60)
+
+    def test_binance_collectors_share_limiter(self):
+        self.assertEqual(exchange_binance_kline.COLLECTOR["rate_limit_keys"],
+                         exchange_binance_symbol.COLLECTOR["rate_limit_keys"])
+
+    def test_fetches_throttled(self):
+        # api.binance.com: 1200 权重/分钟（20/秒）、burst 100；
+        # exchangeInfo 权重 20 + 45 次现货 K线各权重 2，共 110，超出 burst 的 10 至少需要 0.5 秒
+        started = self.clock.now
+        with FakeExchange() as fx:
+            fx.static[("api.binance.com", "/api/v3/exchangeInfo")] = {"symbols": []}
+            symbol_response = trigger([make_job("symbols", "symbol", inst_type="SPOT")])
+            limiter = _ratelimit._limiters["api.binance.com"]
+            self.assertEqual(limiter.stats()["acquired_total"], 1)
+            self.assertEqual(self.clock.slept, [])
+
+            kline_response = trigger([make_job(f"t{i}", "kline", "1m", inst_type="SPOT", symbol="BTCUSDT")
+                                      for i in range(45)])
+
+        self.assertEqual(results_by_task(symbol_response)["symbols"]["status"], 2)
+        self.assertTrue(all(r["status"] == 2 for r in kline_response["task_results"]))
+        self.assertEqual(len(fx.calls), 46)
+        stats = limiter.stats()
+        self.assertEqual(stats["acquired_total"], 46)
+        self.assertEqual(stats["last_acquired_at"], round(self.clock.now, 3))
+        self.assertGreaterEqual(self.clock.now - started, 0.5 - 1e-3)
+        self.assertEqual(list(_ratelimit._limiters), ["api.binance.com"])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,99 @@
+import os
+import sys
+import tempfile
+import textwrap
+import unittest
+from unittest import mock
+
+from .fakes import load_main
+
+try:
+    import yaml
+except ImportError:
+    yaml = None
+
+main = load_main()
+
+_COLLECTOR_MODULE = """
+def collect(jobs, get_best_ip):
+    return {{"task_results": [], "write_groups": []}}
+
+COLLECTOR = {{"data_type": "{data_type}", "collect": collect}}
+"""
+
+
+class DiscoverCollectorsTest(unittest.TestCase):
+    def setUp(self):
+        self._dir = tempfile.TemporaryDirectory()
+        self.addCleanup(self._dir.cleanup)
+        sys.path.insert(0, self._dir.name)
+        self.addCleanup(sys.path.remove, self._dir.name)
+        for patcher in (mock.patch.object(main, "_PLUGIN_DIR", self._dir.name),
+                        mock.patch.dict(main._collector_registry, clear=True)):
+            patcher.start()
+            self.addCleanup(patcher.stop)
+
+    def _write(self, module_name: str, source: str):
+        with open(os.path.join(self._dir.name, f"{module_name}.py"), "w", encoding="utf-8") as f:
+            f.write(textwrap.dedent(source))
+        self.addCleanup(sys.modules.pop, module_name, None)
+
+    def test_registers_collectors(self):
+        self._write("registry_test_a", _COLLECTOR_MODULE.format(data_type="type_a"))
+        self._write("registry_test_b", _COLLECTOR_MODULE.format(data_type="type_b"))
+        self._write("registry_test_plain", "VALUE = 1\n")
+        self._write("registry_test_broken", "raise ImportError('boom')\n")
+        self._write("_registry_test_helper", _COLLECTOR_MODULE.format(data_type="helper"))
+        main._discover_collectors()
+        self.assertEqual(sorted(main._collector_registry), ["type_a", "type_b"])
+
+    def test_duplicate_data_type_fails(self):
+        self._write("registry_test_a", _COLLECTOR_MODULE.format(data_type="dup"))
+        self._write("registry_test_b", _COLLECTOR_MODULE.format(data_type="dup"))
+        with self.assertRaises(RuntimeError) as ctx:
+            main._discover_collectors()
+        self.assertIn("registry_test_a", str(ctx.exception))
+        self.assertIn("registry_test_b", str(ctx.exception))
+
+
+class RepoCollectorsTest(unittest.TestCase):
+    def test_no_duplicates_in_repo(self):
+        # 仓库中的插件可以完整注册（重复的 data_type 会在 load_main 时直接失败）
+        self.assertEqual(sorted(main._collector_registry), [
+            "coinbase_kline", "coinbase_symbol", "kline", "kraken_kline", "kraken_symbol", "okx_kline", "symbol",
+        ])
+
+
+@unittest.skipUnless(yaml, "未安装 pyyaml")
+class SupportedCollectorsTest(unittest.TestCase):
+    def setUp(self):
+        self._dir = tempfile.TemporaryDirectory()
+        self.addCleanup(self._dir.cleanup)
+        self.config_path = os.path.join(self._dir.name, "config.yaml")
+
+    def _write_config(self, supported: list[str]):
+        with open(self.config_path, "w", encoding="utf-8") as f:
+            yaml.safe_dump({"plugin": {"supported_collectors": supported}}, f)
+
+    def test_all_registered(self):
+        self._write_config(["kline", "symbol"])
+        main._validate_supported_collectors(self.config_path)
+
+    def test_missing_collector_fails(self):
+        self._write_config(["kline", "funding_rate"])
+        with self.assertRaises(RuntimeError) as ctx:
+            main._validate_supported_collectors(self.config_path)
+        self.assertIn("funding_rate", str(ctx.exception))
+
+    def test_missing_config_skipped(self):
+        main._validate_supported_collectors(os.path.join(self._dir.name, "missing.yaml"))
+
+    def test_missing_pyyaml_fails(self):
+        self._write_config(["kline"])
+        with mock.patch.dict(sys.modules, {"yaml": None}):
+            with self.assertRaises(RuntimeError):
+                main._validate_supported_collectors(self.config_path)
+
+
+if __name__ == "__main__":
+    unittest.main()
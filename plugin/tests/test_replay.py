@@ -0,0 +1,78 @@
+import os
+import tempfile
+import unittest
+from unittest import mock
+from urllib.error import URLError
+
+import _replay
+
+from .fakes import make_job, reset_kline_state, results_by_task, trigger
+
+# 录制模式下经 FakeExchange 生成的响应（见 README 4.8），每个交易所一组：
+#   Binance 合约最近 5 根 1m、OKX 合约 150 根 1h 区间（2 页）、Coinbase 最近 5 根 1h
+_FIXTURES_DIR = os.path.join(os.path.dirname(__file__), "fixtures")
+
+_JOBS = [
+    make_job("binance", "kline", "1m", inst_type="SWAP", symbol="BTCUSDT"),
+    make_job("okx", "okx_kline", "1h", inst_type="SWAP", symbol="BTC-USDT",
+             start_time="2024-01-01 00:00:00", end_time="2024-01-07 06:00:00"),
+    make_job("coinbase", "coinbase_kline", "1h", symbol="BTC-USD"),
+]
+
+
+class ReplayFixturesTest(unittest.TestCase):
+    def setUp(self):
+        reset_kline_state()
+        for patcher in (mock.patch.object(_replay, "REPLAY_DIR", _FIXTURES_DIR),
+                        mock.patch.object(_replay, "RECORD_DIR", "")):
+            patcher.start()
+            self.addCleanup(patcher.stop)
+
+    def _run(self) -> dict:
+        reset_kline_state()
+        return trigger(_JOBS)
+
+    def test_deterministic(self):
+        first = self._run()
+        self.assertEqual(self._run(), first)
+
+        self.assertTrue(all(r["status"] == 2 for r in first["task_results"]))
+        groups = {(g["dataset_id"], g["freq"]): g["data_points"] for g in first["write_groups"]}
+        self.assertEqual({k: len(v) for k, v in groups.items()}, {(100, "1m"): 5, (200, "1H"): 150, (301, "1H"): 5})
+        okx = groups[(200, "1H")]
+        self.assertEqual((okx[0]["times"], okx[-1]["times"]), ("2024-01-01 00:00:00", "2024-01-07 05:00:00"))
+        self.assertEqual(groups[(301, "1H")][-1]["times"], "2024-03-01 00:00:00")
+
+    def test_missing_fixture_fails_task(self):
+        result = results_by_task(trigger([make_job("eth", "kline", "1m", inst_type="SWAP", symbol="ETHUSDT")]))["eth"]
+        self.assertEqual(result["status"], 4)
+        self.assertIn("回放录制不存在", result["result"])
+
+
+class RecordTest(unittest.TestCase):
+    def setUp(self):
+        self._dir = tempfile.TemporaryDirectory()
+        self.addCleanup(self._dir.cleanup)
+        for patcher in (mock.patch.object(_replay, "RECORD_DIR", self._dir.name),
+                        mock.patch.object(_replay, "REPLAY_DIR", self._dir.name)):
+            patcher.start()
+            self.addCleanup(patcher.stop)
+
+    def test_time_params_fallback(self):
+        path = "/fapi/v1/klines?symbol=BTCUSDT&interval=1m&endTime=1709251229999&limit=5"
+        _replay.save("fapi.binance.com", path, 200, None, [[1]])
+        # 按完整路径与去掉时间参数后的路径各保存一份
+        self.assertEqual(len(os.listdir(os.path.join(self._dir.name, "fapi.binance.com"))), 2)
+
+        later = path.replace("1709251229999", "1709251289999")
+        self.assertEqual(_replay.load("fapi.binance.com", later)[2], [[1]])
+
+    def test_paging_cursor_not_stripped(self):
+        path = "/fapi/v1/klines?symbol=BTCUSDT&interval=1m&startTime=0&endTime=59999&limit=1000"
+        _replay.save("fapi.binance.com", path, 200, None, [[0]])
+        with self.assertRaises(URLError):
+            _replay.load("fapi.binance.com", path.replace("startTime=0", "startTime=60000"))
+
+
+if __name__ == "__main__":
+    unittest.main()
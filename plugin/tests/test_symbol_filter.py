@@ -0,0 +1,133 @@
+import unittest
+
+import exchange_binance_symbol
+import exchange_coinbase_symbol
+import exchange_kraken_symbol
+from _symbols import filter_values, normalize_symbol_set
+
+from .fakes import make_job
+
+
+def _binance(base: str, quote: str, status: str = "TRADING", contract_type: str = "") -> dict:
+    return {"symbol": f"{base}{quote}", "baseAsset": base, "quoteAsset": quote, "status": status,
+            "contractType": contract_type}
+
+
+# 录制的 exchangeInfo 响应（节选）
+_BINANCE_SPOT = [
+    _binance("BTC", "USDT"),
+    _binance("ETH", "USDT"),
+    _binance("BNB", "FDUSD"),
+    _binance("ETH", "BTC"),
+    _binance("SOL", "BUSD", status="BREAK"),
+    _binance("LUNA", "USDT", status="BREAK"),
+]
+
+_BINANCE_SWAP = [
+    _binance("BTC", "USDT", contract_type="PERPETUAL"),
+    _binance("BTC", "USDT", contract_type="CURRENT_QUARTER"),
+    _binance("ETH", "USDC", contract_type="PERPETUAL"),
+]
+
+_COINBASE_PRODUCTS = [
+    {"product_id": "BTC-USD", "base_currency_id": "BTC", "quote_currency_id": "USD", "status": "online"},
+    {"product_id": "ETH-USDC", "base_currency_id": "ETH", "quote_currency_id": "USDC", "status": "online"},
+    {"product_id": "ETH-EUR", "base_currency_id": "ETH", "quote_currency_id": "EUR", "status": "online"},
+    {"product_id": "SOL-USD", "base_currency_id": "SOL", "quote_currency_id": "USD", "status": "online",
+     "trading_disabled": True},
+    {"product_id": "DOGE-USD", "base_currency_id": "DOGE", "quote_currency_id": "USD", "status": "delisted"},
+]
+
+_KRAKEN_PAIRS = {
+    "XXBTZUSD": {"altname": "XBTUSD", "wsname": "XBT/USD", "base": "XXBT", "quote": "ZUSD", "status": "online"},
+    "XXBTZEUR": {"altname": "XBTEUR", "wsname": "XBT/EUR", "base": "XXBT", "quote": "ZEUR", "status": "online"},
+    "ETHUSDT": {"altname": "ETHUSDT", "wsname": "ETH/USDT", "base": "XETH", "quote": "USDT", "status": "online"},
+}
+
+
+_filter_binance = exchange_binance_symbol._filter_symbols
+
+
+def _symbols(result: list[dict]) -> list[str]:
+    return sorted(s["symbol"] for s in result)
+
+
+class BinanceSymbolFilterTest(unittest.TestCase):
+    def test_default(self):
+        for symbol_filter in (None, {}):
+            with self.subTest(symbol_filter=symbol_filter):
+                self.assertEqual(_symbols(_filter_binance(_BINANCE_SPOT, "SPOT", symbol_filter)),
+                                 ["BTC-USDT", "ETH-USDT"])
+
+    def test_multiple_quote_assets(self):
+        symbol_filter = {"quote_assets": ["USDT", "FDUSD", "BTC"]}
+        self.assertEqual(_symbols(_filter_binance(_BINANCE_SPOT, "SPOT", symbol_filter)),
+                         ["BNB-FDUSD", "BTC-USDT", "ETH-BTC", "ETH-USDT"])
+
+    def test_statuses(self):
+        symbol_filter = {"quote_assets": ["USDT", "BUSD"], "statuses": ["TRADING", "BREAK"]}
+        self.assertEqual(_symbols(_filter_binance(_BINANCE_SPOT, "SPOT", symbol_filter)),
+                         ["BTC-USDT", "ETH-USDT", "LUNA-USDT", "SOL-BUSD"])
+
+    def test_exclude_and_include(self):
+        # 黑白名单的各种写法都按规范写法匹配
+        symbol_filter = {"quote_assets": ["USDT", "BTC"], "exclude_symbols": ["eth/btc", "BTCUSDT"]}
+        self.assertEqual(_symbols(_filter_binance(_BINANCE_SPOT, "SPOT", symbol_filter)),
+                         ["ETH-USDT"])
+        symbol_filter = {"include_symbols": ["BTC-USDT", "ETH-BTC"], "exclude_symbols": ["ETHUSDT"]}
+        self.assertEqual(_symbols(_filter_binance(_BINANCE_SPOT, "SPOT", symbol_filter)),
+                         ["BTC-USDT"])
+
+    def test_swap_perpetual_only(self):
+        symbol_filter = {"quote_assets": ["USDT", "USDC"]}
+        self.assertEqual(_symbols(_filter_binance(_BINANCE_SWAP, "SWAP", symbol_filter)),
+                         ["BTC-USDT", "ETH-USDC"])
+
+    def test_parse_job_bad_filter_falls_back(self):
+        job = exchange_binance_symbol.parse_job(make_job("t", "symbol", inst_type="SPOT", symbol_filter="USDT"))
+        self.assertEqual(job["symbol_filter"], {})
+
+
+class FilterValuesTest(unittest.TestCase):
+    def test_bare_string_is_single_item(self):
+        self.assertEqual(filter_values({"quote_assets": "FDUSD"}, "quote_assets", ("USDT",)), {"FDUSD"})
+        self.assertEqual(normalize_symbol_set("btc/usdt"), {"BTCUSDT"})
+
+    def test_bad_type_falls_back_to_default(self):
+        for value in (123, {"USDT": True}, ["USDT", 1]):
+            with self.subTest(value=value):
+                self.assertEqual(filter_values({"quote_assets": value}, "quote_assets", ("USDT",)), {"USDT"})
+        self.assertEqual(normalize_symbol_set({"BTCUSDT": True}), set())
+
+    def test_bare_string_quote_asset_applied(self):
+        symbol_filter = {"quote_assets": "FDUSD"}
+        self.assertEqual(_symbols(_filter_binance(_BINANCE_SPOT, "SPOT", symbol_filter)),
+                         ["BNB-FDUSD"])
+
+
+class CoinbaseSymbolFilterTest(unittest.TestCase):
+    def test_default(self):
+        self.assertEqual(_symbols(exchange_coinbase_symbol._filter_products(_COINBASE_PRODUCTS)),
+                         ["BTC-USD", "ETH-USDC"])
+
+    def test_config(self):
+        symbol_filter = {"quote_assets": ["USD", "USDC", "EUR"], "statuses": ["online", "delisted"],
+                         "exclude_symbols": "ETH/USDC"}
+        # trading_disabled 的交易对始终剔除
+        self.assertEqual(_symbols(exchange_coinbase_symbol._filter_products(_COINBASE_PRODUCTS, symbol_filter)),
+                         ["BTC-USD", "DOGE-USD", "ETH-EUR"])
+
+
+class KrakenSymbolFilterTest(unittest.TestCase):
+    def test_default(self):
+        self.assertEqual(_symbols(exchange_kraken_symbol._filter_pairs(_KRAKEN_PAIRS)), ["BTC-USD", "ETH-USDT"])
+
+    def test_config(self):
+        # Kraken 写法（XBTEUR）与规范写法（BTC-USD）均可用于黑白名单
+        symbol_filter = {"quote_assets": ["USD", "EUR"], "include_symbols": ["XBTEUR", "BTC-USD", "ETH-USDT"]}
+        self.assertEqual(_symbols(exchange_kraken_symbol._filter_pairs(_KRAKEN_PAIRS, symbol_filter)),
+                         ["BTC-EUR", "BTC-USD"])
+
+
+if __name__ == "__main__":
+    unittest.main()
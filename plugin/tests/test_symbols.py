@@ -0,0 +1,106 @@
+import unittest
+
+import _kraken
+import exchange_binance_kline
+import exchange_binance_symbol
+import exchange_coinbase_kline
+import exchange_coinbase_symbol
+import exchange_kraken_kline
+import exchange_kraken_symbol
+import exchange_okx_kline
+from _symbols import canonical_symbol, split_symbol, symbol_key
+
+from .fakes import FakeExchange, make_job, reset_kline_state, results_by_task, trigger
+
+
+class SplitSymbolTest(unittest.TestCase):
+    def test_separators_and_case(self):
+        for raw in ("BTC-USDT", "btc/usdt", "BTC_USDT", "BTCUSDT", "BTC-USDT-SWAP", " btcusdt "):
+            with self.subTest(raw=raw):
+                self.assertEqual(split_symbol(raw), ("BTC", "USDT"))
+
+    def test_asset_aliases(self):
+        self.assertEqual(split_symbol("XBT/USD"), ("BTC", "USD"))
+        self.assertEqual(canonical_symbol("xdg", "usdt"), "DOGE-USDT")
+
+    def test_stablecoin_quotes(self):
+        self.assertEqual(split_symbol("BNB-FDUSD"), ("BNB", "FDUSD"))
+        self.assertEqual(split_symbol("ETHUSDC"), ("ETH", "USDC"))
+
+    def test_ambiguous_and_unknown_quotes_rejected(self):
+        # BNBFDUSD 可拆为 BNB-FDUSD 或 BNBFD-USD，不做猜测
+        for raw in ("BNBFDUSD", "BTCTUSD", "BTCBUSD", "BTCTRY", "USDT", ""):
+            with self.subTest(raw=raw):
+                with self.assertRaises(ValueError):
+                    split_symbol(raw)
+
+    def test_symbol_key(self):
+        self.assertEqual(symbol_key("XBT/USDT"), "BTCUSDT")
+        self.assertEqual(symbol_key("btc-try"), "BTCTRY")
+
+
+class CanonicalAcrossExchangesTest(unittest.TestCase):
+    """同一交易对在各交易所的写法不同，写入存储的 symbol / object_id 必须一致。"""
+
+    def _kline_symbol(self, module, interval, **params):
+        return module.parse_job(make_job("t", module.COLLECTOR["data_type"], interval, **params))["symbol"]
+
+    def test_kline_plugins(self):
+        cases = [
+            (exchange_binance_kline, {"inst_type": "SPOT", "symbol": "BTCUSDT"}, "BTC-USDT"),
+            (exchange_okx_kline, {"inst_type": "SPOT", "symbol": "BTC-USDT"}, "BTC-USDT"),
+            (exchange_okx_kline, {"inst_type": "SWAP", "symbol": "btc/usdt"}, "BTC-USDT"),
+            (exchange_coinbase_kline, {"symbol": "BTC-USD"}, "BTC-USD"),
+            (exchange_kraken_kline, {"symbol": "XBTUSD"}, "BTC-USD"),
+            (exchange_kraken_kline, {"symbol": "XXBTZUSD"}, "BTC-USD"),
+        ]
+        for module, params, expected in cases:
+            with self.subTest(module=module.__name__, **params):
+                self.assertEqual(self._kline_symbol(module, "1m", **params), expected)
+
+    def test_symbol_plugins(self):
+        binance = exchange_binance_symbol._filter_symbols(
+            [{"symbol": "BTCUSDT", "baseAsset": "BTC", "quoteAsset": "USDT", "status": "TRADING"}], "SPOT")
+        coinbase = exchange_coinbase_symbol._filter_products(
+            [{"product_id": "BTC-USDT", "base_currency_id": "BTC", "quote_currency_id": "USDT", "status": "online"}],
+            {"quote_assets": ["USDT"]})
+        kraken = exchange_kraken_symbol._filter_pairs(
+            {"XBTUSDT": {"altname": "XBTUSDT", "base": "XXBT", "quote": "USDT", "status": "online"}})
+        self.assertEqual([s["symbol"] for s in binance], ["BTC-USDT"])
+        self.assertEqual([s["symbol"] for s in coinbase], ["BTC-USDT"])
+        self.assertEqual([s["symbol"] for s in kraken], ["BTC-USDT"])
+
+    def test_kraken_pair_codes(self):
+        self.assertEqual(_kraken.to_pair("BTC-USD"), "XBTUSD")
+        self.assertEqual(_kraken.split_symbol("XETHZUSD"), ("ETH", "USD"))
+
+
+class InvalidSymbolTest(unittest.TestCase):
+    def setUp(self):
+        reset_kline_state()
+
+    def test_binance_falls_back_to_raw_symbol(self):
+        job = exchange_binance_kline.parse_job(make_job("t", "kline", "1m", inst_type="SPOT", symbol="btctry"))
+        self.assertEqual(job["symbol"], "BTCTRY")
+        job = exchange_binance_kline.parse_job(make_job("t", "kline", "1m", inst_type="SPOT", symbol="BNBFDUSD"))
+        self.assertEqual(job["symbol"], "BNBFDUSD")
+
+    def test_invalid_symbols_fail_only_their_task(self):
+        with FakeExchange():
+            response = trigger([
+                make_job("non-str", "okx_kline", "1m", inst_type="SPOT", symbol=123),
+                make_job("ambiguous", "coinbase_kline", "1m", symbol="BTCBUSD"),
+                make_job("missing", "kraken_kline", "1m"),
+                make_job("ok", "okx_kline", "1m", inst_type="SPOT", symbol="BTC-USDT"),
+            ])
+        results = results_by_task(response)
+        self.assertEqual(results["non-str"]["status"], 4)
+        self.assertEqual(results["ambiguous"]["status"], 4)
+        self.assertIn("歧义", results["ambiguous"]["result"])
+        self.assertEqual(results["missing"]["status"], 4)
+        self.assertEqual(results["ok"]["status"], 2)
+        self.assertEqual(len(response["write_groups"]), 1)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,85 @@
+import unittest
+
+import exchange_binance_kline
+import exchange_coinbase_kline
+import exchange_kraken_kline
+import exchange_okx_kline
+from _timeutil import is_aligned, kline_times, next_open_ms, parse_time_ms, parse_time_range
+
+# 2024-01-01 00:00:00 UTC（周一）
+_OPEN_MS = 1_704_067_200_000
+
+
+class KlineTimesTest(unittest.TestCase):
+    def test_close_time(self):
+        cases = [
+            ("1m", "2024-01-01 00:00:59"),
+            ("1h", "2024-01-01 00:59:59"),
+            ("1d", "2024-01-01 23:59:59"),
+            ("1w", "2024-01-07 23:59:59"),
+            ("1M", "2024-01-31 23:59:59"),
+        ]
+        for interval, close_time in cases:
+            with self.subTest(interval=interval):
+                self.assertEqual(kline_times(_OPEN_MS, interval), ("2024-01-01 00:00:00", close_time))
+
+    def test_same_bar_across_exchanges(self):
+        # 同一根 1h K线在各交易所响应中的写法（Binance / OKX 毫秒，Coinbase / Kraken 秒）
+        klines = {
+            "binance": exchange_binance_kline._parse_item(
+                [_OPEN_MS, "1", "2", "0.5", "1.5", "10", _OPEN_MS + 3_599_999, "15", 3], "1h"),
+            "okx": exchange_okx_kline._parse_row([str(_OPEN_MS), "1", "2", "0.5", "1.5", "10", "10", "15", "1"], "1h"),
+            "coinbase": exchange_coinbase_kline._parse_candle(
+                {"start": str(_OPEN_MS // 1000), "low": "0.5", "high": "2", "open": "1", "close": "1.5",
+                 "volume": "10"}, "1h"),
+            "kraken": exchange_kraken_kline._parse_row([_OPEN_MS // 1000, "1", "2", "0.5", "1.5", "1.2", "10", 3],
+                                                       "1h"),
+        }
+        for exchange, kline in klines.items():
+            with self.subTest(exchange=exchange):
+                self.assertEqual((kline["open_ms"], kline["open_time"], kline["close_time"]),
+                                 (_OPEN_MS, "2024-01-01 00:00:00", "2024-01-01 00:59:59"))
+
+    def test_next_open_ms(self):
+        self.assertEqual(next_open_ms(_OPEN_MS, "1h"), _OPEN_MS + 3_600_000)
+        # 1M 按自然月推进（1 月 31 天、闰年 2 月 29 天）
+        feb = next_open_ms(_OPEN_MS, "1M")
+        self.assertEqual(kline_times(feb, "1M"), ("2024-02-01 00:00:00", "2024-02-29 23:59:59"))
+        dec = parse_time_ms("2023-12-01 00:00:00")
+        self.assertEqual(next_open_ms(dec, "1M"), _OPEN_MS)
+
+    def test_is_aligned(self):
+        # 周线起始日各交易所不同，只校验为 0 点
+        self.assertTrue(is_aligned(_OPEN_MS + 3 * 86_400_000, "1w"))
+        self.assertFalse(is_aligned(_OPEN_MS + 3_600_000, "1w"))
+        self.assertTrue(is_aligned(_OPEN_MS + 4 * 3_600_000, "4h"))
+        self.assertFalse(is_aligned(_OPEN_MS + 60_000, "5m"))
+        self.assertTrue(is_aligned(parse_time_ms("2024-03-01 00:00:00"), "1M"))
+        self.assertFalse(is_aligned(parse_time_ms("2024-03-02 00:00:00"), "1M"))
+
+
+class ParseTimeTest(unittest.TestCase):
+    def test_formats(self):
+        for value in (_OPEN_MS, float(_OPEN_MS), str(_OPEN_MS), "2024-01-01 00:00:00"):
+            with self.subTest(value=value):
+                self.assertEqual(parse_time_ms(value), _OPEN_MS)
+        self.assertIsNone(parse_time_ms(None))
+        self.assertIsNone(parse_time_ms(""))
+
+    def test_rejects(self):
+        for value in ([_OPEN_MS], {"ms": _OPEN_MS}, True, float("nan"), float("inf"), "2024-01-01", "yesterday"):
+            with self.subTest(value=value):
+                with self.assertRaises(ValueError):
+                    parse_time_ms(value)
+
+    def test_time_range(self):
+        self.assertEqual(parse_time_range({"start_time": "2024-01-01 00:00:00"}), (_OPEN_MS, None))
+        self.assertEqual(parse_time_range({}), (None, None))
+        for end_time in ("2024-01-01 00:00:00", "2023-12-31 00:00:00"):
+            with self.subTest(end_time=end_time):
+                with self.assertRaises(ValueError):
+                    parse_time_range({"start_time": "2024-01-01 00:00:00", "end_time": end_time})
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,116 @@
+import threading
+import unittest
+from unittest import mock
+
+import _ttlcache
+import exchange_binance_symbol
+from _ttlcache import TTLCache
+
+from .fakes import FakeClock, FakeExchange
+
+
+class TTLCacheTest(unittest.TestCase):
+    def setUp(self):
+        self.clock = FakeClock()
+        patcher = mock.patch.object(_ttlcache, "time", self.clock)
+        patcher.start()
+        self.addCleanup(patcher.stop)
+        self.loads = 0
+
+    def _loader(self, value="v"):
+        def load():
+            self.loads += 1
+            return value
+        return load
+
+    def test_hit_within_ttl(self):
+        cache = TTLCache("t", ttl_seconds=60)
+        self.assertEqual(cache.get_or_load("k", self._loader()), "v")
+        self.clock.now += 59
+        self.assertEqual(cache.get_or_load("k", self._loader()), "v")
+        self.assertEqual(self.loads, 1)
+        stats = cache.stats()
+        self.assertEqual((stats["hits_total"], stats["misses_total"]), (1, 1))
+
+    def test_reload_after_expiry(self):
+        cache = TTLCache("t", ttl_seconds=60)
+        cache.get_or_load("k", self._loader("old"))
+        self.clock.now += 60
+        self.assertEqual(cache.get_or_load("k", self._loader("new")), "new")
+        self.assertEqual(self.loads, 2)
+
+    def test_keys_are_independent(self):
+        cache = TTLCache("t", ttl_seconds=60)
+        cache.get_or_load("SPOT", self._loader("spot"))
+        self.assertEqual(cache.get_or_load("SWAP", self._loader("swap")), "swap")
+        self.assertEqual(self.loads, 2)
+
+    def test_failed_load_not_cached(self):
+        cache = TTLCache("t", ttl_seconds=60)
+        with self.assertRaises(RuntimeError):
+            cache.get_or_load("k", mock.Mock(side_effect=RuntimeError("boom")))
+        self.assertEqual(cache.get_or_load("k", self._loader()), "v")
+        self.assertEqual(self.loads, 1)
+
+    def test_invalidate(self):
+        cache = TTLCache("t", ttl_seconds=60)
+        cache.get_or_load("k", self._loader())
+        cache.invalidate("k")
+        cache.get_or_load("k", self._loader())
+        self.assertEqual(self.loads, 2)
+
+
+class CoalescingTest(unittest.TestCase):
+    def test_concurrent_loads_coalesced(self):
+        cache = TTLCache("t", ttl_seconds=60)
+        started, release = threading.Event(), threading.Event()
+        calls = []
+
+        def slow_loader():
+            calls.append(1)
+            started.set()
+            release.wait(5)
+            return "v"
+
+        results = []
+        threads = [threading.Thread(target=lambda: results.append(cache.get_or_load("k", slow_loader)))
+                   for _ in range(8)]
+        threads[0].start()
+        self.assertTrue(started.wait(5))
+        for t in threads[1:]:
+            t.start()
+        release.set()
+        for t in threads:
+            t.join(5)
+
+        self.assertEqual(calls, [1])
+        self.assertEqual(results, ["v"] * 8)
+        stats = cache.stats()
+        self.assertEqual(stats["misses_total"], 1)
+        self.assertEqual(stats["hits_total"] + stats["coalesced_total"], 7)
+
+
+class ExchangeInfoCacheTest(unittest.TestCase):
+    def setUp(self):
+        _ttlcache.get_cache("binance_exchange_info", 300).invalidate()
+        self.addCleanup(_ttlcache.get_cache("binance_exchange_info", 300).invalidate)
+
+    def test_jobs_share_one_exchange_info_request(self):
+        symbols = [{"symbol": "BTCUSDT", "baseAsset": "BTC", "quoteAsset": "USDT", "status": "TRADING"},
+                   {"symbol": "ETHBTC", "baseAsset": "ETH", "quoteAsset": "BTC", "status": "TRADING"}]
+        jobs = [
+            {"task_id": "usdt", "inst_type": "SPOT", "symbol_filter": {}},
+            {"task_id": "btc", "inst_type": "SPOT", "symbol_filter": {"quote_assets": ["BTC"]}},
+        ]
+        with FakeExchange() as fx:
+            fx.static[("api.binance.com", "/api/v3/exchangeInfo")] = {"symbols": symbols}
+            result = exchange_binance_symbol.collect_symbols(jobs, lambda domain: None)
+            exchange_binance_symbol.collect_symbols(jobs[:1], lambda domain: None)
+
+        self.assertEqual(len(fx.calls), 1)
+        objects = [dp["object_id"] for dp in result["write_groups"][0]["data_points"]]
+        self.assertEqual(objects, ["BTC-USDT", "ETH-BTC"])
+
+
+if __name__ == "__main__":
+    unittest.main()